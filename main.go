@@ -67,12 +67,22 @@ func main() {
 		stop()
 		os.Exit(1)
 	}
+	for _, d := range cfg.Deprecations() {
+		logger.WarnContext(ctx, "deprecated configuration", slog.String("detail", d))
+	}
 
 	s := server.New(e, &cfg,
 		server.WithK8sClient[struct{}](k8sClient),
 		server.WithLogger[struct{}](logger),
 	)
 
+	watcher := config.NewWatcher(logger, k8sClient, s.UpdateConfig)
+	go func() {
+		if err := watcher.Run(ctx); err != nil {
+			logger.ErrorContext(ctx, "failed to watch config", slog.String("error", err.Error()))
+		}
+	}()
+
 	// Start server
 	if err := s.Start(ctx); err != nil {
 		logger.ErrorContext(ctx, "failed to create k8s client", slog.String("error", err.Error()))
@@ -81,21 +91,21 @@ func main() {
 	}
 }
 
-func NewClient(kubeconfigFilePath string) (client.Client, error) {
+func NewClient(kubeconfigFilePath string) (client.WithWatch, error) {
 	cfg, err := loadKubeconfigFromFile(kubeconfigFilePath)
 	if err != nil {
 		cfg, err = rest.InClusterConfig()
 		if err != nil {
 			return nil, errors.WithStack(err)
 		}
-		k8sClient, err := client.New(cfg, client.Options{})
+		k8sClient, err := client.NewWithWatch(cfg, client.Options{})
 		if err != nil {
 			return nil, errors.WithStack(err)
 		}
 		return k8sClient, nil
 	}
 
-	k8sClient, err := client.New(cfg, client.Options{})
+	k8sClient, err := client.NewWithWatch(cfg, client.Options{})
 	if err != nil {
 		return nil, errors.WithStack(err)
 	}
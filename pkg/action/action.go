@@ -13,3 +13,17 @@ type Action interface {
 		result dispatcher.DispatchResult,
 	) error
 }
+
+// ListAttrsAction is implemented by the actions that take YAML lists in their attrs.
+// each element of the lists is rendered separately, so that a value of the alert cannot add elements.
+type ListAttrsAction interface {
+	ListAttrs() []string
+}
+
+// ListAttrs returns the attrs of the action that are YAML lists.
+func ListAttrs(a Action) []string {
+	if l, ok := a.(ListAttrsAction); ok {
+		return l.ListAttrs()
+	}
+	return nil
+}
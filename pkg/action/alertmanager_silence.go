@@ -0,0 +1,285 @@
+package action
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Drumato/amgate/pkg/alertmanager"
+	"github.com/Drumato/amgate/pkg/dispatcher"
+	"github.com/cockroachdb/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+type AlertmanagerSilenceAction struct {
+	logger     *slog.Logger
+	httpClient *http.Client
+}
+
+func (a *AlertmanagerSilenceAction) Name() string {
+	return "alertmanager-silence"
+}
+
+func (a *AlertmanagerSilenceAction) Run(ctx context.Context, result dispatcher.DispatchResult) error {
+	cfg, err := a.collectConfig(result.Attrs, result.Alert)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, cfg.Timeout)
+	defer cancel()
+
+	matchers := cfg.matchers(result.Alert.Alert.Labels)
+	if len(matchers) == 0 {
+		return errors.New("no label of the alert matches match_labels")
+	}
+
+	// the silences created by amgate for the same matchers are reused,
+	// so that the repeated notifications extend the silence instead of creating another one.
+	existing, err := a.findSilences(ctx, cfg, matchers)
+	if err != nil {
+		return err
+	}
+
+	logger := a.logger.With(slog.String("url", cfg.URL.Redacted()), slog.Any("matchers", matchers))
+	switch cfg.Operation {
+	case "create":
+		silence := alertmanager.Silence{
+			Matchers:  matchers,
+			StartsAt:  time.Now(),
+			EndsAt:    time.Now().Add(cfg.Duration),
+			CreatedBy: cfg.CreatedBy,
+			Comment:   cfg.Comment,
+		}
+		if len(existing) > 0 {
+			silence.ID = existing[0].ID
+			silence.StartsAt = existing[0].StartsAt
+		}
+
+		if cfg.DryRun {
+			// dry-run
+			logger.Info("dry-run", slog.String("operation", cfg.Operation), slog.String("id", silence.ID), slog.Time("endsAt", silence.EndsAt))
+			return nil
+		}
+
+		body, err := json.Marshal(silence)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		resp := alertmanager.PostSilenceResponse{}
+		if err := a.do(ctx, http.MethodPost, cfg.URL.JoinPath("/api/v2/silences"), body, &resp); err != nil {
+			return err
+		}
+		logger.InfoContext(ctx, "silence created", slog.String("id", resp.SilenceID), slog.Time("endsAt", silence.EndsAt))
+		SetOutput(ctx, "silence_id", resp.SilenceID)
+	case "expire":
+		for _, silence := range existing {
+			if cfg.DryRun {
+				// dry-run
+				logger.Info("dry-run", slog.String("operation", cfg.Operation), slog.String("id", silence.ID))
+				continue
+			}
+
+			if err := a.do(ctx, http.MethodDelete, cfg.URL.JoinPath("/api/v2/silence", silence.ID), nil, nil); err != nil {
+				return err
+			}
+			logger.InfoContext(ctx, "silence expired", slog.String("id", silence.ID))
+		}
+	}
+
+	return nil
+}
+
+// findSilences returns the active or pending silences created by amgate with the same matchers.
+func (a *AlertmanagerSilenceAction) findSilences(ctx context.Context, cfg AlertmanagerSilenceConfig, matchers []alertmanager.SilenceMatcher) ([]alertmanager.Silence, error) {
+	u := cfg.URL.JoinPath("/api/v2/silences")
+	query := url.Values{}
+	for _, m := range matchers {
+		query.Add("filter", fmt.Sprintf("%s=%q", m.Name, m.Value))
+	}
+	u.RawQuery = query.Encode()
+
+	silences := []alertmanager.Silence{}
+	if err := a.do(ctx, http.MethodGet, u, nil, &silences); err != nil {
+		return nil, err
+	}
+
+	found := []alertmanager.Silence{}
+	for _, silence := range silences {
+		if silence.CreatedBy != cfg.CreatedBy {
+			continue
+		}
+		if silence.Status != nil && silence.Status.State == "expired" {
+			continue
+		}
+		// isEqual is not compared as the older Alertmanager omits it.
+		if !slices.EqualFunc(sortedMatchers(silence.Matchers), matchers, func(a, b alertmanager.SilenceMatcher) bool {
+			return a.Name == b.Name && a.Value == b.Value && a.IsRegex == b.IsRegex
+		}) {
+			continue
+		}
+		found = append(found, silence)
+	}
+	return found, nil
+}
+
+func (a *AlertmanagerSilenceAction) do(ctx context.Context, method string, u *url.URL, body []byte, out any) error {
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := a.httpClient.Do(req)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		// the status is converted into the reason like the http-request action.
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseBodyLog))
+		statusErr := apierrors.NewGenericServerResponse(resp.StatusCode, method, schema.GroupResource{}, "", string(respBody), 0, true)
+		return errors.Wrapf(statusErr, "%s %s responded %d", method, u.Redacted(), resp.StatusCode)
+	}
+
+	if out == nil {
+		return nil
+	}
+	return errors.WithStack(json.NewDecoder(resp.Body).Decode(out))
+}
+
+func sortedMatchers(matchers []alertmanager.SilenceMatcher) []alertmanager.SilenceMatcher {
+	return slices.SortedFunc(slices.Values(matchers), func(a, b alertmanager.SilenceMatcher) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+}
+
+type AlertmanagerSilenceConfig struct {
+	DryRun bool
+
+	// URL is the base URL of Alertmanager, defaults to the externalURL of the payload.
+	URL *url.URL
+	// Operation is one of create and expire.
+	Operation string
+	// MatchLabels are the labels of the alert used as the matchers.
+	// all labels are used if it is empty.
+	MatchLabels []string
+
+	Duration  time.Duration
+	Comment   string
+	CreatedBy string
+	// Timeout is the timeout of the requests.
+	Timeout time.Duration
+}
+
+// matchers returns the equality matchers of the labels sorted by name.
+func (c AlertmanagerSilenceConfig) matchers(labels map[string]string) []alertmanager.SilenceMatcher {
+	names := c.MatchLabels
+	if len(names) == 0 {
+		names = slices.Collect(maps.Keys(labels))
+	}
+
+	matchers := []alertmanager.SilenceMatcher{}
+	for _, name := range names {
+		value, ok := labels[name]
+		if !ok {
+			continue
+		}
+		matchers = append(matchers, alertmanager.SilenceMatcher{Name: name, Value: value, IsEqual: true})
+	}
+	return sortedMatchers(matchers)
+}
+
+func (a *AlertmanagerSilenceAction) collectConfig(attrs map[string]string, alert dispatcher.DispatchAlert) (AlertmanagerSilenceConfig, error) {
+	cfg := AlertmanagerSilenceConfig{}
+
+	rawURL := alert.ExternalURL
+	if v, ok := attrs["url"]; ok {
+		rawURL = v
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return AlertmanagerSilenceConfig{}, errors.Newf("invalid url %q", rawURL)
+	}
+	cfg.URL = u
+
+	cfg.Operation = "create"
+	if operation, ok := attrs["operation"]; ok {
+		cfg.Operation = operation
+	}
+	switch cfg.Operation {
+	case "create", "expire":
+	default:
+		return AlertmanagerSilenceConfig{}, errors.Newf("unsupported operation %q", cfg.Operation)
+	}
+
+	if matchLabels, ok := attrs["match_labels"]; ok {
+		for _, name := range strings.Split(matchLabels, ",") {
+			cfg.MatchLabels = append(cfg.MatchLabels, strings.TrimSpace(name))
+		}
+	}
+
+	cfg.Duration = time.Hour
+	durationCfg, ok := attrs["duration"]
+	if ok {
+		duration, err := time.ParseDuration(durationCfg)
+		if err != nil || duration <= 0 {
+			return AlertmanagerSilenceConfig{}, errors.Newf("invalid duration %q", durationCfg)
+		}
+		cfg.Duration = duration
+	}
+
+	cfg.Comment = "silenced by amgate"
+	if comment, ok := attrs["comment"]; ok {
+		cfg.Comment = comment
+	}
+	cfg.CreatedBy = "amgate"
+	if createdBy, ok := attrs["created_by"]; ok {
+		cfg.CreatedBy = createdBy
+	}
+
+	dryRunCfg, ok := attrs["dry_run"]
+	if ok {
+		dryRun, err := strconv.ParseBool(dryRunCfg)
+		if err != nil {
+			return AlertmanagerSilenceConfig{}, errors.Wrap(err, "invalid dry_run")
+		}
+		cfg.DryRun = dryRun
+	}
+
+	cfg.Timeout = 10 * time.Second
+	timeoutCfg, ok := attrs["timeout"]
+	if ok {
+		timeout, err := time.ParseDuration(timeoutCfg)
+		if err != nil {
+			return AlertmanagerSilenceConfig{}, errors.Wrap(err, "invalid timeout")
+		}
+		cfg.Timeout = timeout
+	}
+
+	return cfg, nil
+}
+
+func NewAlertmanagerSilenceAction(
+	logger *slog.Logger,
+) Action {
+	actionLogger := logger.With(slog.String("action", "alertmanager-silence"))
+	return &AlertmanagerSilenceAction{
+		logger:     actionLogger,
+		httpClient: http.DefaultClient,
+	}
+}
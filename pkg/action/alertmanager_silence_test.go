@@ -0,0 +1,227 @@
+package action_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Drumato/amgate/pkg/action"
+	"github.com/Drumato/amgate/pkg/alertmanager"
+	"github.com/Drumato/amgate/pkg/dispatcher"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeAlertmanager serves the silences API of Alertmanager v2.
+type fakeAlertmanager struct {
+	mu       sync.Mutex
+	silences map[string]alertmanager.Silence
+	nextID   int
+}
+
+func (f *fakeAlertmanager) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/api/v2/silences":
+		silences := []alertmanager.Silence{}
+		for _, s := range f.silences {
+			silences = append(silences, s)
+		}
+		_ = json.NewEncoder(w).Encode(silences)
+	case r.Method == http.MethodPost && r.URL.Path == "/api/v2/silences":
+		silence := alertmanager.Silence{}
+		if err := json.NewDecoder(r.Body).Decode(&silence); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if silence.ID == "" {
+			f.nextID++
+			silence.ID = fmt.Sprint(f.nextID)
+		} else if _, ok := f.silences[silence.ID]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		silence.Status = &alertmanager.SilenceStatus{State: "active"}
+		f.silences[silence.ID] = silence
+		_ = json.NewEncoder(w).Encode(alertmanager.PostSilenceResponse{SilenceID: silence.ID})
+	case r.Method == http.MethodDelete && strings.HasPrefix(r.URL.Path, "/api/v2/silence/"):
+		id := strings.TrimPrefix(r.URL.Path, "/api/v2/silence/")
+		silence, ok := f.silences[id]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		silence.Status = &alertmanager.SilenceStatus{State: "expired"}
+		f.silences[id] = silence
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestAlertmanagerSilenceAction_Run(t *testing.T) {
+	labels := map[string]string{"alertname": "HighLatency", "namespace": "checkout", "pod": "checkout-0"}
+
+	tests := []struct {
+		name     string
+		existing []alertmanager.Silence
+		attrs    map[string]string
+		verifyFn func(map[string]alertmanager.Silence)
+		wantErr  bool
+	}{
+		{
+			name: "create",
+			attrs: map[string]string{
+				"duration":   "30m",
+				"comment":    "restarted checkout",
+				"created_by": "amgate-checkout",
+			},
+			verifyFn: func(silences map[string]alertmanager.Silence) {
+				assert.Len(t, silences, 1)
+				silence := silences["1"]
+				assert.Equal(t, []alertmanager.SilenceMatcher{
+					{Name: "alertname", Value: "HighLatency", IsEqual: true},
+					{Name: "namespace", Value: "checkout", IsEqual: true},
+					{Name: "pod", Value: "checkout-0", IsEqual: true},
+				}, silence.Matchers)
+				assert.Equal(t, "restarted checkout", silence.Comment)
+				assert.Equal(t, "amgate-checkout", silence.CreatedBy)
+				assert.WithinDuration(t, time.Now().Add(30*time.Minute), silence.EndsAt, time.Minute)
+			},
+		},
+		{
+			name:  "match labels",
+			attrs: map[string]string{"match_labels": "alertname, namespace"},
+			verifyFn: func(silences map[string]alertmanager.Silence) {
+				assert.Equal(t, []alertmanager.SilenceMatcher{
+					{Name: "alertname", Value: "HighLatency", IsEqual: true},
+					{Name: "namespace", Value: "checkout", IsEqual: true},
+				}, silences["1"].Matchers)
+			},
+		},
+		{
+			name: "extend the existing silence",
+			existing: []alertmanager.Silence{
+				{
+					ID:        "1",
+					Matchers:  []alertmanager.SilenceMatcher{{Name: "alertname", Value: "HighLatency"}},
+					EndsAt:    time.Now().Add(time.Minute),
+					CreatedBy: "amgate",
+					Status:    &alertmanager.SilenceStatus{State: "active"},
+				},
+				{
+					// created by someone else.
+					ID:        "2",
+					Matchers:  []alertmanager.SilenceMatcher{{Name: "alertname", Value: "HighLatency"}},
+					EndsAt:    time.Now().Add(time.Minute),
+					CreatedBy: "oncall",
+					Status:    &alertmanager.SilenceStatus{State: "active"},
+				},
+			},
+			attrs: map[string]string{"match_labels": "alertname", "duration": "2h"},
+			verifyFn: func(silences map[string]alertmanager.Silence) {
+				assert.Len(t, silences, 2)
+				assert.WithinDuration(t, time.Now().Add(2*time.Hour), silences["1"].EndsAt, time.Minute)
+				assert.WithinDuration(t, time.Now().Add(time.Minute), silences["2"].EndsAt, time.Minute)
+			},
+		},
+		{
+			name: "expire",
+			existing: []alertmanager.Silence{
+				{
+					ID:        "1",
+					Matchers:  []alertmanager.SilenceMatcher{{Name: "alertname", Value: "HighLatency"}},
+					CreatedBy: "amgate",
+					Status:    &alertmanager.SilenceStatus{State: "active"},
+				},
+				{
+					// the matchers differ.
+					ID:        "2",
+					Matchers:  []alertmanager.SilenceMatcher{{Name: "alertname", Value: "HighLatency"}, {Name: "pod", Value: "checkout-0"}},
+					CreatedBy: "amgate",
+					Status:    &alertmanager.SilenceStatus{State: "active"},
+				},
+			},
+			attrs: map[string]string{"match_labels": "alertname", "operation": "expire"},
+			verifyFn: func(silences map[string]alertmanager.Silence) {
+				assert.Equal(t, "expired", silences["1"].Status.State)
+				assert.Equal(t, "active", silences["2"].Status.State)
+			},
+		},
+		{
+			name:  "dry run",
+			attrs: map[string]string{"dry_run": "true"},
+			verifyFn: func(silences map[string]alertmanager.Silence) {
+				assert.Empty(t, silences)
+			},
+		},
+		{
+			name:    "unsupported operation",
+			attrs:   map[string]string{"operation": "delete"},
+			wantErr: true,
+		},
+		{
+			name:    "no matching label",
+			attrs:   map[string]string{"match_labels": "service"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			am := &fakeAlertmanager{silences: map[string]alertmanager.Silence{}}
+			for _, s := range tt.existing {
+				am.silences[s.ID] = s
+				am.nextID++
+			}
+			ts := httptest.NewServer(am)
+			defer ts.Close()
+
+			logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+			a := action.NewAlertmanagerSilenceAction(logger)
+
+			err := a.Run(t.Context(), dispatcher.DispatchResult{
+				Alert: dispatcher.DispatchAlert{
+					Alert:       alertmanager.Alert{Labels: labels},
+					ExternalURL: ts.URL,
+				},
+				Attrs: tt.attrs,
+			})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Run() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if tt.verifyFn != nil {
+				am.mu.Lock()
+				defer am.mu.Unlock()
+				tt.verifyFn(am.silences)
+			}
+		})
+	}
+}
+
+func TestAlertmanagerSilenceAction_Run_URL(t *testing.T) {
+	ts := httptest.NewServer(&fakeAlertmanager{silences: map[string]alertmanager.Silence{}})
+	defer ts.Close()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	a := action.NewAlertmanagerSilenceAction(logger)
+
+	// the url attr overrides the unreachable externalURL.
+	err := a.Run(t.Context(), dispatcher.DispatchResult{
+		Alert: dispatcher.DispatchAlert{
+			Alert:       alertmanager.Alert{Labels: map[string]string{"alertname": "HighLatency"}},
+			ExternalURL: "http://alertmanager.invalid:9093",
+		},
+		Attrs: map[string]string{"url": ts.URL},
+	})
+	assert.NoError(t, err)
+}
@@ -0,0 +1,198 @@
+package action
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Drumato/amgate/pkg/dispatcher"
+	"github.com/cockroachdb/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// maxOutputLog is the maximum size of stdout and stderr kept for logging.
+const maxOutputLog = 64 * 1024
+
+// execPath is the PATH of the commands, as they do not inherit the environment of amgate.
+const execPath = "/usr/local/bin:/usr/bin:/bin"
+
+type ExecAction struct {
+	logger *slog.Logger
+	// allowedCommands returns the allowlist of the current configuration.
+	allowedCommands func() []string
+}
+
+func (a *ExecAction) Name() string {
+	return "exec"
+}
+
+// ListAttrs makes each argument rendered separately,
+// so that a label value cannot add arguments to the allowed command.
+func (a *ExecAction) ListAttrs() []string {
+	return []string{"args"}
+}
+
+func (a *ExecAction) Run(ctx context.Context, result dispatcher.DispatchResult) error {
+	cfg, err := a.collectConfig(result.Attrs)
+	if err != nil {
+		return err
+	}
+	if !slices.Contains(a.allowedCommands(), cfg.Command) {
+		return errors.Newf("command %q is not in exec.allowedCommands", cfg.Command)
+	}
+
+	logger := a.logger.With(slog.String("command", cfg.Command), slog.Any("args", cfg.Args))
+	if cfg.DryRun {
+		// dry-run
+		logger.Info("dry-run")
+		return nil
+	}
+
+	stdin, err := json.Marshal(result.Alert)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, cfg.Timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, cfg.Command, cfg.Args...)
+	// the command runs in a clean environment without the credentials of amgate.
+	cmd.Env = []string{"PATH=" + execPath}
+	for _, env := range alertEnv(result.Alert) {
+		cmd.Env = append(cmd.Env, env.Name+"="+env.Value)
+	}
+	cmd.Stdin = bytes.NewReader(stdin)
+	stdout := &limitedBuffer{limit: maxOutputLog}
+	stderr := &limitedBuffer{limit: maxOutputLog}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	// the pipes are closed even if the command leaves its children running.
+	cmd.WaitDelay = time.Second
+
+	start := time.Now()
+	err = cmd.Run()
+	exitCode := cmd.ProcessState.ExitCode()
+	logger = logger.With(
+		slog.Int("exitCode", exitCode),
+		slog.Duration("duration", time.Since(start)),
+		slog.String("stdout", stdout.String()),
+		slog.String("stderr", stderr.String()),
+	)
+	SetOutput(ctx, "exit_code", strconv.Itoa(exitCode))
+	SetOutput(ctx, "stdout", stdout.String())
+
+	if ctx.Err() != nil {
+		logger.ErrorContext(ctx, "command timed out")
+		return errors.Wrapf(ctx.Err(), "command %q did not finish within %s", cfg.Command, cfg.Timeout)
+	}
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		// the command could not be started.
+		return errors.WithStack(err)
+	}
+	if !slices.Contains(cfg.SuccessExitCodes, exitCode) {
+		logger.ErrorContext(ctx, "command failed")
+		return errors.Newf("command %q exited with code %d", cfg.Command, exitCode)
+	}
+
+	logger.InfoContext(ctx, "command succeeded")
+	return nil
+}
+
+// limitedBuffer keeps the first limit bytes and discards the rest.
+type limitedBuffer struct {
+	buf   bytes.Buffer
+	limit int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if rest := b.limit - b.buf.Len(); rest > 0 {
+		b.buf.Write(p[:min(len(p), rest)])
+	}
+	return len(p), nil
+}
+
+func (b *limitedBuffer) String() string {
+	return b.buf.String()
+}
+
+type ExecConfig struct {
+	DryRun bool
+
+	// Command is the absolute path of the command in exec.allowedCommands.
+	Command string
+	Args    []string
+	// Timeout kills the command if it does not finish in time.
+	Timeout time.Duration
+	// SuccessExitCodes are the exit codes regarded as success.
+	SuccessExitCodes []int
+}
+
+func (a *ExecAction) collectConfig(attrs map[string]string) (ExecConfig, error) {
+	cfg := ExecConfig{}
+	cfg.Command = attrs["command"]
+	if !filepath.IsAbs(cfg.Command) {
+		return ExecConfig{}, errors.Newf("command %q must be an absolute path", cfg.Command)
+	}
+	cfg.Command = filepath.Clean(cfg.Command)
+
+	argsCfg, ok := attrs["args"]
+	if ok {
+		if err := yaml.Unmarshal([]byte(argsCfg), &cfg.Args); err != nil {
+			return ExecConfig{}, errors.Wrap(err, "invalid args")
+		}
+	}
+
+	dryRunCfg, ok := attrs["dry_run"]
+	if ok {
+		dryRun, err := strconv.ParseBool(dryRunCfg)
+		if err != nil {
+			return ExecConfig{}, errors.Wrap(err, "invalid dry_run")
+		}
+		cfg.DryRun = dryRun
+	}
+
+	cfg.Timeout = 30 * time.Second
+	timeoutCfg, ok := attrs["timeout"]
+	if ok {
+		timeout, err := time.ParseDuration(timeoutCfg)
+		if err != nil {
+			return ExecConfig{}, errors.Wrap(err, "invalid timeout")
+		}
+		cfg.Timeout = timeout
+	}
+
+	cfg.SuccessExitCodes = []int{0}
+	successExitCodesCfg, ok := attrs["success_exit_codes"]
+	if ok {
+		cfg.SuccessExitCodes = []int{}
+		for _, code := range strings.Split(successExitCodesCfg, ",") {
+			exitCode, err := strconv.Atoi(strings.TrimSpace(code))
+			if err != nil {
+				return ExecConfig{}, errors.Newf("invalid success_exit_codes %q", successExitCodesCfg)
+			}
+			cfg.SuccessExitCodes = append(cfg.SuccessExitCodes, exitCode)
+		}
+	}
+
+	return cfg, nil
+}
+
+func NewExecAction(
+	logger *slog.Logger,
+	allowedCommands func() []string,
+) Action {
+	actionLogger := logger.With(slog.String("action", "exec"))
+	return &ExecAction{
+		logger:          actionLogger,
+		allowedCommands: allowedCommands,
+	}
+}
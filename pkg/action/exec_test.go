@@ -0,0 +1,155 @@
+package action_test
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Drumato/amgate/pkg/action"
+	"github.com/Drumato/amgate/pkg/alertmanager"
+	"github.com/Drumato/amgate/pkg/dispatcher"
+	"github.com/stretchr/testify/assert"
+)
+
+// writeScript writes an executable shell script into dir.
+func writeScript(t *testing.T, dir, name, script string) string {
+	t.Helper()
+
+	path := filepath.Join(dir, name)
+	assert.NoError(t, os.WriteFile(path, []byte("#!/bin/sh\n"+script), 0o755))
+	return path
+}
+
+func TestExecAction_Run(t *testing.T) {
+	dir := t.TempDir()
+	record := writeScript(t, dir, "record.sh", `cat > "$1"; env > "$2"`)
+	fail := writeScript(t, dir, "fail.sh", `echo "failed to restart" >&2; exit 3`)
+	sleep := writeScript(t, dir, "sleep.sh", `sleep 5`)
+	notAllowed := writeScript(t, dir, "not-allowed.sh", `exit 0`)
+	allowed := []string{record, fail, sleep}
+
+	tests := []struct {
+		name     string
+		attrs    map[string]string
+		verifyFn func(stdinFile, envFile string)
+		wantErr  bool
+	}{
+		{
+			name:  "stdin and env",
+			attrs: map[string]string{"command": record},
+			verifyFn: func(stdinFile, envFile string) {
+				stdin, err := os.ReadFile(stdinFile)
+				assert.NoError(t, err)
+				alert := dispatcher.DispatchAlert{}
+				assert.NoError(t, json.Unmarshal(stdin, &alert))
+				assert.Equal(t, "HighLatency", alert.Alert.Labels["alertname"])
+
+				env, err := os.ReadFile(envFile)
+				assert.NoError(t, err)
+				assert.Contains(t, string(env), "AMGATE_STATUS=firing\n")
+				assert.Contains(t, string(env), "AMGATE_LABEL_ALERTNAME=HighLatency\n")
+				assert.Contains(t, string(env), "AMGATE_ANNOTATION_SUMMARY=latency is high\n")
+				assert.NotContains(t, string(env), "AMGATE_TEST_SECRET")
+			},
+		},
+		{
+			name:    "not allowed",
+			attrs:   map[string]string{"command": notAllowed},
+			wantErr: true,
+		},
+		{
+			name:    "relative path",
+			attrs:   map[string]string{"command": "record.sh"},
+			wantErr: true,
+		},
+		{
+			name:    "exit code",
+			attrs:   map[string]string{"command": fail},
+			wantErr: true,
+		},
+		{
+			name:  "success exit codes",
+			attrs: map[string]string{"command": fail, "success_exit_codes": "0, 3"},
+		},
+		{
+			name:    "timeout",
+			attrs:   map[string]string{"command": sleep, "timeout": "100ms"},
+			wantErr: true,
+		},
+		{
+			name:  "dry run",
+			attrs: map[string]string{"command": record, "dry_run": "true"},
+			verifyFn: func(stdinFile, envFile string) {
+				assert.NoFileExists(t, stdinFile)
+				assert.NoFileExists(t, envFile)
+			},
+		},
+	}
+
+	t.Setenv("AMGATE_TEST_SECRET", "s3cr3t")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+			a := action.NewExecAction(logger, func() []string { return allowed })
+
+			out := t.TempDir()
+			stdinFile, envFile := filepath.Join(out, "stdin"), filepath.Join(out, "env")
+			attrs := map[string]string{
+				"args": "[" + strings.Join([]string{stdinFile, envFile}, ", ") + "]",
+			}
+			for k, v := range tt.attrs {
+				attrs[k] = v
+			}
+
+			err := a.Run(t.Context(), dispatcher.DispatchResult{
+				Alert: dispatcher.DispatchAlert{
+					Alert: alertmanager.Alert{
+						Status:      "firing",
+						Labels:      map[string]string{"alertname": "HighLatency"},
+						Annotations: map[string]string{"summary": "latency is high"},
+					},
+				},
+				Attrs: attrs,
+			})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Run() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if tt.verifyFn != nil {
+				tt.verifyFn(stdinFile, envFile)
+			}
+		})
+	}
+}
+
+func TestExecAction_Run_RenderedArgs(t *testing.T) {
+	dir := t.TempDir()
+	out := filepath.Join(dir, "args")
+	record := writeScript(t, dir, "record.sh", `printf '%s\n' "$@" > "`+out+`"`)
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	a := action.NewExecAction(logger, func() []string { return []string{record} })
+
+	alert := dispatcher.DispatchAlert{
+		Alert: alertmanager.Alert{
+			// tries to inject an argument.
+			Labels: map[string]string{"region": `x", "--force`},
+		},
+	}
+	attrs, err := dispatcher.RenderAttrs(map[string]string{
+		"command": record,
+		"args":    `["--region", "{{ .Alert.Labels.region }}"]`,
+	}, alert, action.ListAttrs(a)...)
+	assert.NoError(t, err)
+
+	assert.NoError(t, a.Run(t.Context(), dispatcher.DispatchResult{Alert: alert, Attrs: attrs}))
+
+	got, err := os.ReadFile(out)
+	assert.NoError(t, err)
+	assert.Equal(t, "--region\nx\", \"--force\n", string(got))
+}
@@ -0,0 +1,431 @@
+package action
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Drumato/amgate/pkg/config"
+	"github.com/Drumato/amgate/pkg/dispatcher"
+	"github.com/cockroachdb/errors"
+	"github.com/go-git/go-git/v5"
+	gitconfig "github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	"gopkg.in/yaml.v3"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+type GitCommitAction struct {
+	logger    *slog.Logger
+	k8sClient client.Client
+}
+
+func (a *GitCommitAction) Name() string {
+	return "git-commit"
+}
+
+func (a *GitCommitAction) Run(ctx context.Context, result dispatcher.DispatchResult) error {
+	cfg, err := a.collectConfig(result.Attrs)
+	if err != nil {
+		return err
+	}
+
+	auth, err := a.auth(ctx, cfg)
+	if err != nil {
+		return err
+	}
+
+	dir, err := os.MkdirTemp("", "amgate-git-")
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	repo, err := git.PlainCloneContext(ctx, dir, false, &git.CloneOptions{
+		URL:           cfg.Repository,
+		Auth:          auth,
+		ReferenceName: plumbing.NewBranchReferenceName(cfg.BaseBranch),
+		SingleBranch:  true,
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to clone %s", cfg.Repository)
+	}
+
+	worktree, err := repo.Worktree()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if cfg.Branch != cfg.BaseBranch {
+		if err := a.checkoutBranch(ctx, repo, worktree, cfg.Branch, auth); err != nil {
+			return err
+		}
+	}
+
+	path, relPath, err := resolveRepoPath(dir, cfg.Path)
+	if err != nil {
+		return err
+	}
+	before, err := os.ReadFile(path)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	after, err := patchYAML(before, cfg.Patch, cfg.Document)
+	if err != nil {
+		return errors.Wrapf(err, "failed to patch %s", cfg.Path)
+	}
+
+	logger := a.logger.With(slog.String("repository", cfg.Repository), slog.String("branch", cfg.Branch), slog.String("path", cfg.Path))
+	if bytes.Equal(before, after) {
+		logger.InfoContext(ctx, "manifest is already up to date")
+		return nil
+	}
+
+	if err := os.WriteFile(path, after, 0o644); err != nil {
+		return errors.WithStack(err)
+	}
+	if _, err := worktree.Add(relPath); err != nil {
+		return errors.WithStack(err)
+	}
+	hash, err := worktree.Commit(cfg.Message, &git.CommitOptions{
+		Author: &object.Signature{
+			Name:  cfg.AuthorName,
+			Email: cfg.AuthorEmail,
+			When:  time.Now(),
+		},
+	})
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	if cfg.DryRun {
+		// dry-run
+		logger.Info("dry-run", slog.String("commit", hash.String()), slog.String("manifest", string(after)))
+		return nil
+	}
+
+	// the push is rejected if the branch is updated concurrently,
+	// and the whole action is retried from the clone according to the retry policy.
+	refSpec := gitconfig.RefSpec("refs/heads/" + cfg.Branch + ":refs/heads/" + cfg.Branch)
+	if err := repo.PushContext(ctx, &git.PushOptions{
+		RemoteName: git.DefaultRemoteName,
+		RefSpecs:   []gitconfig.RefSpec{refSpec},
+		Auth:       auth,
+	}); err != nil {
+		return errors.Wrapf(err, "failed to push to %s", cfg.Repository)
+	}
+
+	logger.InfoContext(ctx, "pushed", slog.String("commit", hash.String()))
+	SetOutput(ctx, "commit", hash.String())
+	return nil
+}
+
+// checkoutBranch checks out the branch from the remote if it exists,
+// so that the commits of the earlier runs are kept and the push is fast-forward.
+// otherwise the branch is created from the base branch.
+func (a *GitCommitAction) checkoutBranch(ctx context.Context, repo *git.Repository, worktree *git.Worktree, branch string, auth transport.AuthMethod) error {
+	remoteRef := plumbing.NewRemoteReferenceName(git.DefaultRemoteName, branch)
+	err := repo.FetchContext(ctx, &git.FetchOptions{
+		RemoteName: git.DefaultRemoteName,
+		RefSpecs:   []gitconfig.RefSpec{gitconfig.RefSpec("refs/heads/" + branch + ":" + remoteRef.String())},
+		Auth:       auth,
+	})
+	noMatchingRef := git.NoMatchingRefSpecError{}
+	switch {
+	case errors.As(err, &noMatchingRef):
+		return errors.WithStack(worktree.Checkout(&git.CheckoutOptions{
+			Branch: plumbing.NewBranchReferenceName(branch),
+			Create: true,
+		}))
+	case err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate):
+		return errors.Wrapf(err, "failed to fetch %s", branch)
+	}
+
+	ref, err := repo.Reference(remoteRef, true)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(worktree.Checkout(&git.CheckoutOptions{
+		Branch: plumbing.NewBranchReferenceName(branch),
+		Hash:   ref.Hash(),
+		Create: true,
+	}))
+}
+
+// resolveRepoPath returns the absolute and the relative path of the file in the cloned repository.
+// the symlinks are resolved, so that a symlinked manifest cannot write outside of the repository.
+func resolveRepoPath(dir, path string) (string, string, error) {
+	realDir, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return "", "", errors.WithStack(err)
+	}
+	realPath, err := filepath.EvalSymlinks(filepath.Join(realDir, path))
+	if err != nil {
+		return "", "", errors.WithStack(err)
+	}
+
+	relPath, err := filepath.Rel(realDir, realPath)
+	if err != nil || !filepath.IsLocal(relPath) || relPath == ".git" || strings.HasPrefix(relPath, ".git"+string(filepath.Separator)) {
+		return "", "", errors.Newf("path %q resolves outside of the repository", path)
+	}
+	return realPath, filepath.ToSlash(relPath), nil
+}
+
+// auth returns the authentication method of the repository, or nil for the anonymous access.
+func (a *GitCommitAction) auth(ctx context.Context, cfg GitCommitConfig) (transport.AuthMethod, error) {
+	if cfg.SSHKeyFile != "" {
+		auth, err := ssh.NewPublicKeysFromFile(cfg.SSHUser, cfg.SSHKeyFile, "")
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read ssh_key_file")
+		}
+		if cfg.SSHKnownHostsFile != "" {
+			callback, err := ssh.NewKnownHostsCallback(cfg.SSHKnownHostsFile)
+			if err != nil {
+				return nil, errors.Wrap(err, "failed to read ssh_known_hosts_file")
+			}
+			auth.HostKeyCallback = callback
+		}
+		return auth, nil
+	}
+
+	if cfg.Token != nil {
+		token, err := cfg.Token.Resolve(ctx, a.k8sClient)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to resolve token")
+		}
+		return &http.BasicAuth{Username: cfg.TokenUsername, Password: token}, nil
+	}
+
+	return nil, nil
+}
+
+// patchYAML merges the patch into the document at the index of the multi-document YAML
+// like the JSON merge patch (RFC 7386): the mappings are merged recursively,
+// the other values are replaced and null removes the key.
+// the order of the keys and the comments of the original document are preserved.
+func patchYAML(src []byte, patch []byte, index int) ([]byte, error) {
+	patchNode := yaml.Node{}
+	if err := yaml.Unmarshal(patch, &patchNode); err != nil {
+		return nil, errors.Wrap(err, "invalid patch")
+	}
+	if len(patchNode.Content) == 0 || patchNode.Content[0].Kind != yaml.MappingNode {
+		return nil, errors.New("patch must be a mapping")
+	}
+
+	docs := []*yaml.Node{}
+	decoder := yaml.NewDecoder(bytes.NewReader(src))
+	for {
+		doc := yaml.Node{}
+		if err := decoder.Decode(&doc); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, errors.WithStack(err)
+		}
+		docs = append(docs, &doc)
+	}
+	if index >= len(docs) {
+		return nil, errors.Newf("document %d is not found in %d documents", index, len(docs))
+	}
+	if len(docs[index].Content) == 0 || docs[index].Content[0].Kind != yaml.MappingNode {
+		return nil, errors.Newf("document %d is not a mapping", index)
+	}
+
+	original, err := encodeYAML(docs)
+	if err != nil {
+		return nil, err
+	}
+	mergeYAMLNode(docs[index].Content[0], patchNode.Content[0])
+	patched, err := encodeYAML(docs)
+	if err != nil {
+		return nil, err
+	}
+
+	// keep the formatting of the file if the patch changes nothing.
+	if bytes.Equal(original, patched) {
+		return src, nil
+	}
+	return patched, nil
+}
+
+func encodeYAML(docs []*yaml.Node) ([]byte, error) {
+	buf := bytes.Buffer{}
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	for _, doc := range docs {
+		if err := encoder.Encode(doc); err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return buf.Bytes(), nil
+}
+
+func mergeYAMLNode(dst, patch *yaml.Node) {
+	for i := 0; i+1 < len(patch.Content); i += 2 {
+		key, value := patch.Content[i], patch.Content[i+1]
+
+		found := -1
+		for j := 0; j+1 < len(dst.Content); j += 2 {
+			if dst.Content[j].Value == key.Value {
+				found = j
+				break
+			}
+		}
+
+		switch {
+		case value.Tag == "!!null" && found >= 0:
+			dst.Content = append(dst.Content[:found], dst.Content[found+2:]...)
+		case value.Tag == "!!null":
+		case found < 0:
+			dst.Content = append(dst.Content, key, value)
+		case value.Kind == yaml.MappingNode && dst.Content[found+1].Kind == yaml.MappingNode:
+			mergeYAMLNode(dst.Content[found+1], value)
+		default:
+			// keep the comments of the original value.
+			value.HeadComment = dst.Content[found+1].HeadComment
+			value.LineComment = dst.Content[found+1].LineComment
+			dst.Content[found+1] = value
+		}
+	}
+}
+
+type GitCommitConfig struct {
+	DryRun bool
+
+	// Repository is the URL or the local path of the repository.
+	Repository string
+	// BaseBranch is the branch to clone.
+	BaseBranch string
+	// Branch is the branch to push, created from BaseBranch if it differs.
+	Branch string
+
+	// Path is the path of the manifest in the repository.
+	Path string
+	// Document is the index of the document in the multi-document manifest.
+	Document int
+	// Patch is the YAML merged into the manifest.
+	Patch []byte
+
+	Message     string
+	AuthorName  string
+	AuthorEmail string
+
+	// SSHKeyFile is the path of the mounted SSH private key.
+	SSHKeyFile        string
+	SSHUser           string
+	SSHKnownHostsFile string
+	// Token is the token for the HTTPS basic authentication.
+	Token         *config.SecretSource
+	TokenUsername string
+}
+
+func (a *GitCommitAction) collectConfig(attrs map[string]string) (GitCommitConfig, error) {
+	cfg := GitCommitConfig{}
+	cfg.Repository = attrs["repository"]
+	if cfg.Repository == "" {
+		return GitCommitConfig{}, errors.New("repository is required")
+	}
+
+	cfg.Branch = "main"
+	if branch, ok := attrs["branch"]; ok {
+		cfg.Branch = branch
+	}
+	cfg.BaseBranch = cfg.Branch
+	if baseBranch, ok := attrs["base_branch"]; ok {
+		cfg.BaseBranch = baseBranch
+	}
+
+	cfg.Path = attrs["path"]
+	if !filepath.IsLocal(cfg.Path) {
+		return GitCommitConfig{}, errors.Newf("invalid path %q", cfg.Path)
+	}
+	documentCfg, ok := attrs["document"]
+	if ok {
+		document, err := strconv.Atoi(documentCfg)
+		if err != nil || document < 0 {
+			return GitCommitConfig{}, errors.Newf("invalid document %q", documentCfg)
+		}
+		cfg.Document = document
+	}
+	patch, ok := attrs["patch"]
+	if !ok {
+		return GitCommitConfig{}, errors.New("patch is required")
+	}
+	cfg.Patch = []byte(patch)
+
+	cfg.Message = "amgate: patch " + cfg.Path
+	if message, ok := attrs["message"]; ok {
+		cfg.Message = message
+	}
+	cfg.AuthorName = "amgate"
+	if authorName, ok := attrs["author_name"]; ok {
+		cfg.AuthorName = authorName
+	}
+	cfg.AuthorEmail = "amgate@localhost"
+	if authorEmail, ok := attrs["author_email"]; ok {
+		cfg.AuthorEmail = authorEmail
+	}
+
+	dryRunCfg, ok := attrs["dry_run"]
+	if ok {
+		dryRun, err := strconv.ParseBool(dryRunCfg)
+		if err != nil {
+			return GitCommitConfig{}, errors.Wrap(err, "invalid dry_run")
+		}
+		cfg.DryRun = dryRun
+	}
+
+	cfg.SSHKeyFile = attrs["ssh_key_file"]
+	cfg.SSHUser = "git"
+	if sshUser, ok := attrs["ssh_user"]; ok {
+		cfg.SSHUser = sshUser
+	}
+	cfg.SSHKnownHostsFile = attrs["ssh_known_hosts_file"]
+
+	token := config.SecretSource{File: attrs["token_file"]}
+	if name, ok := attrs["token_secret_name"]; ok {
+		token.SecretRef = &config.SecretKeyRef{
+			Namespace: attrs["token_secret_namespace"],
+			Name:      name,
+			Key:       attrs["token_secret_key"],
+		}
+	}
+	if token.File != "" || token.SecretRef != nil {
+		if cfg.SSHKeyFile != "" {
+			return GitCommitConfig{}, errors.New("ssh_key_file and token are exclusive")
+		}
+		if err := token.ValidateAndDefault(); err != nil {
+			return GitCommitConfig{}, errors.Wrap(err, "invalid token")
+		}
+		cfg.Token = &token
+	}
+	cfg.TokenUsername = "git"
+	if tokenUsername, ok := attrs["token_username"]; ok {
+		cfg.TokenUsername = tokenUsername
+	}
+
+	return cfg, nil
+}
+
+func NewGitCommitAction(
+	logger *slog.Logger,
+	k8sClient client.Client,
+) Action {
+	actionLogger := logger.With(slog.String("action", "git-commit"))
+	return &GitCommitAction{
+		logger:    actionLogger,
+		k8sClient: k8sClient,
+	}
+}
@@ -0,0 +1,310 @@
+package action_test
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Drumato/amgate/pkg/action"
+	"github.com/Drumato/amgate/pkg/dispatcher"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/stretchr/testify/assert"
+)
+
+const testManifest = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: checkout
+spec:
+  # scaled by amgate
+  replicas: 2
+  template:
+    spec:
+      containers:
+        - name: checkout
+          image: checkout:v1
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: checkout
+`
+
+// newTestRemote creates a bare repository with the manifest on the main branch.
+func newTestRemote(t *testing.T) string {
+	t.Helper()
+
+	work := t.TempDir()
+	repo, err := git.PlainInitWithOptions(work, &git.PlainInitOptions{
+		InitOptions: git.InitOptions{DefaultBranch: plumbing.Main},
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, os.MkdirAll(filepath.Join(work, "apps"), 0o755))
+	assert.NoError(t, os.WriteFile(filepath.Join(work, "apps", "checkout.yaml"), []byte(testManifest), 0o644))
+
+	worktree, err := repo.Worktree()
+	assert.NoError(t, err)
+	_, err = worktree.Add("apps/checkout.yaml")
+	assert.NoError(t, err)
+	_, err = worktree.Commit("initial commit", &git.CommitOptions{
+		Author: &object.Signature{Name: "test", Email: "test@localhost", When: time.Now()},
+	})
+	assert.NoError(t, err)
+
+	remote := t.TempDir()
+	_, err = git.PlainClone(remote, true, &git.CloneOptions{URL: work})
+	assert.NoError(t, err)
+	return remote
+}
+
+// readRemoteFile returns the commit message and the file at the head of the branch.
+func readRemoteFile(t *testing.T, remote, branch, path string) (string, string) {
+	t.Helper()
+
+	repo, err := git.PlainOpen(remote)
+	assert.NoError(t, err)
+	ref, err := repo.Reference(plumbing.NewBranchReferenceName(branch), true)
+	if err != nil {
+		return "", ""
+	}
+	commit, err := repo.CommitObject(ref.Hash())
+	assert.NoError(t, err)
+	file, err := commit.File(path)
+	assert.NoError(t, err)
+	contents, err := file.Contents()
+	assert.NoError(t, err)
+	return commit.Message, contents
+}
+
+func TestGitCommitAction_Run(t *testing.T) {
+	tests := []struct {
+		name         string
+		attrs        map[string]string
+		branch       string
+		wantMessage  string
+		wantManifest string
+		wantErr      bool
+	}{
+		{
+			name: "patch and push",
+			attrs: map[string]string{
+				"patch":   "spec:\n  replicas: 4\n",
+				"message": "scale checkout for HighLatency",
+			},
+			branch:      "main",
+			wantMessage: "scale checkout for HighLatency",
+			wantManifest: `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: checkout
+spec:
+  # scaled by amgate
+  replicas: 4
+  template:
+    spec:
+      containers:
+        - name: checkout
+          image: checkout:v1
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: checkout
+`,
+		},
+		{
+			name: "push to a new branch",
+			attrs: map[string]string{
+				"branch":      "amgate/scale-checkout",
+				"base_branch": "main",
+				"patch":       "metadata:\n  labels:\n    scaled: \"true\"\n",
+			},
+			branch:      "amgate/scale-checkout",
+			wantMessage: "amgate: patch apps/checkout.yaml",
+			wantManifest: `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: checkout
+  labels:
+    scaled: "true"
+spec:
+  # scaled by amgate
+  replicas: 2
+  template:
+    spec:
+      containers:
+        - name: checkout
+          image: checkout:v1
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: checkout
+`,
+		},
+		{
+			name: "patch the second document",
+			attrs: map[string]string{
+				"document": "1",
+				"patch":    "metadata:\n  name: null\n  namespace: default\n",
+			},
+			branch:      "main",
+			wantMessage: "amgate: patch apps/checkout.yaml",
+			wantManifest: `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: checkout
+spec:
+  # scaled by amgate
+  replicas: 2
+  template:
+    spec:
+      containers:
+        - name: checkout
+          image: checkout:v1
+---
+apiVersion: v1
+kind: Service
+metadata:
+  namespace: default
+`,
+		},
+		{
+			name: "no changes",
+			attrs: map[string]string{
+				"patch": "spec:\n  replicas: 2\n",
+			},
+			branch:       "main",
+			wantMessage:  "initial commit",
+			wantManifest: testManifest,
+		},
+		{
+			name: "dry run",
+			attrs: map[string]string{
+				"patch":   "spec:\n  replicas: 4\n",
+				"dry_run": "true",
+			},
+			branch:       "main",
+			wantMessage:  "initial commit",
+			wantManifest: testManifest,
+		},
+		{
+			name: "path outside of the repository",
+			attrs: map[string]string{
+				"path":  "../checkout.yaml",
+				"patch": "spec:\n  replicas: 4\n",
+			},
+			branch:       "main",
+			wantMessage:  "initial commit",
+			wantManifest: testManifest,
+			wantErr:      true,
+		},
+		{
+			name: "document not found",
+			attrs: map[string]string{
+				"document": "2",
+				"patch":    "spec:\n  replicas: 4\n",
+			},
+			branch:       "main",
+			wantMessage:  "initial commit",
+			wantManifest: testManifest,
+			wantErr:      true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+			remote := newTestRemote(t)
+			a := action.NewGitCommitAction(logger, nil)
+
+			attrs := map[string]string{
+				"repository": "file://" + remote,
+				"path":       "apps/checkout.yaml",
+			}
+			for k, v := range tt.attrs {
+				attrs[k] = v
+			}
+
+			err := a.Run(t.Context(), dispatcher.DispatchResult{
+				Attrs: attrs,
+			})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Run() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			message, manifest := readRemoteFile(t, remote, tt.branch, "apps/checkout.yaml")
+			assert.Equal(t, tt.wantMessage, message)
+			assert.Equal(t, tt.wantManifest, manifest)
+		})
+	}
+}
+
+func TestGitCommitAction_Run_ExistingBranch(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	remote := newTestRemote(t)
+	a := action.NewGitCommitAction(logger, nil)
+
+	// the second run patches the branch pushed by the first run.
+	for _, patch := range []string{"spec:\n  replicas: 3\n", "metadata:\n  labels:\n    scaled: \"true\"\n"} {
+		err := a.Run(t.Context(), dispatcher.DispatchResult{
+			Attrs: map[string]string{
+				"repository":  "file://" + remote,
+				"path":        "apps/checkout.yaml",
+				"branch":      "amgate/scale-checkout",
+				"base_branch": "main",
+				"patch":       patch,
+			},
+		})
+		assert.NoError(t, err)
+	}
+
+	_, manifest := readRemoteFile(t, remote, "amgate/scale-checkout", "apps/checkout.yaml")
+	assert.Contains(t, manifest, "replicas: 3")
+	assert.Contains(t, manifest, "scaled: \"true\"")
+}
+
+func TestGitCommitAction_Run_Symlink(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	remote := newTestRemote(t)
+	// the repository is cloned into tmp, so the link below points to tmp/outside.yaml.
+	tmp := t.TempDir()
+	t.Setenv("TMPDIR", tmp)
+	outside := filepath.Join(tmp, "outside.yaml")
+	assert.NoError(t, os.WriteFile(outside, []byte(testManifest), 0o644))
+
+	// push a symlink pointing outside of the repository.
+	work := t.TempDir()
+	repo, err := git.PlainClone(work, false, &git.CloneOptions{URL: remote})
+	assert.NoError(t, err)
+	assert.NoError(t, os.Symlink("../../outside.yaml", filepath.Join(work, "apps", "link.yaml")))
+	worktree, err := repo.Worktree()
+	assert.NoError(t, err)
+	_, err = worktree.Add("apps/link.yaml")
+	assert.NoError(t, err)
+	_, err = worktree.Commit("add link", &git.CommitOptions{
+		Author: &object.Signature{Name: "test", Email: "test@localhost", When: time.Now()},
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, repo.Push(&git.PushOptions{}))
+
+	a := action.NewGitCommitAction(logger, nil)
+	err = a.Run(t.Context(), dispatcher.DispatchResult{
+		Attrs: map[string]string{
+			"repository": "file://" + remote,
+			"path":       "apps/link.yaml",
+			"patch":      "spec:\n  replicas: 4\n",
+		},
+	})
+	assert.ErrorContains(t, err, "resolves outside of the repository")
+
+	contents, err := os.ReadFile(outside)
+	assert.NoError(t, err)
+	assert.Equal(t, testManifest, string(contents))
+}
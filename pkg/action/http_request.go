@@ -0,0 +1,276 @@
+package action
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Drumato/amgate/pkg/config"
+	"github.com/Drumato/amgate/pkg/dispatcher"
+	"github.com/cockroachdb/errors"
+	"gopkg.in/yaml.v3"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// maxResponseBodyLog is the maximum size of the response body included in the error.
+const maxResponseBodyLog = 1024
+
+type HTTPRequestAction struct {
+	logger    *slog.Logger
+	k8sClient client.Client
+}
+
+func (a *HTTPRequestAction) Name() string {
+	return "http-request"
+}
+
+func (a *HTTPRequestAction) Run(ctx context.Context, result dispatcher.DispatchResult) error {
+	cfg, err := a.collectConfig(result.Attrs)
+	if err != nil {
+		return err
+	}
+
+	body := cfg.Body
+	if body == nil {
+		// the default body is the dispatched alert.
+		b, err := json.Marshal(result.Alert)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		body = b
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, cfg.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, cfg.Method, cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "amgate")
+	for k, v := range cfg.Headers {
+		req.Header.Set(k, v)
+	}
+	if cfg.Auth != nil {
+		credential, err := cfg.Auth.Resolve(ctx, a.k8sClient)
+		if err != nil {
+			return errors.Wrap(err, "failed to resolve auth")
+		}
+		req.Header.Set(cfg.AuthHeader, cfg.AuthPrefix+credential)
+	}
+
+	logger := a.logger.With(slog.String("method", cfg.Method), slog.String("url", req.URL.Redacted()))
+	if cfg.DryRun {
+		// dry-run
+		logger.Info("dry-run", slog.String("body", string(body)))
+		return nil
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = cfg.TLSConfig
+	// the transport is not reused by the later runs, so its keep-alive connections are closed.
+	defer transport.CloseIdleConnections()
+	httpClient := &http.Client{Transport: transport}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseBodyLog))
+	SetOutput(ctx, "status_code", strconv.Itoa(resp.StatusCode))
+	SetOutput(ctx, "body", string(respBody))
+	if !cfg.isSuccess(resp.StatusCode) {
+		// the status is converted into the reason like the Kubernetes API,
+		// so that 429 and 5xx are retried according to retry.retryableReasons.
+		retryAfter, _ := strconv.Atoi(resp.Header.Get("Retry-After"))
+		statusErr := apierrors.NewGenericServerResponse(resp.StatusCode, cfg.Method, schema.GroupResource{}, "", string(respBody), retryAfter, true)
+		return errors.Wrapf(statusErr, "%s %s responded %d", cfg.Method, req.URL.Redacted(), resp.StatusCode)
+	}
+
+	logger.InfoContext(ctx, "request succeeded", slog.Int("status", resp.StatusCode))
+	return nil
+}
+
+type HTTPRequestConfig struct {
+	DryRun bool
+
+	Method string
+	URL    string
+	// Headers are the request headers.
+	Headers map[string]string
+	// Body is the request body, or nil to send the dispatched alert as JSON.
+	Body []byte
+	// Timeout is the timeout of the request.
+	Timeout time.Duration
+
+	// TLSConfig is built from ca_file and insecure_skip_verify.
+	TLSConfig *tls.Config
+
+	// Auth is the credential set to AuthHeader with AuthPrefix.
+	Auth       *config.SecretSource
+	AuthHeader string
+	AuthPrefix string
+
+	// SuccessStatuses are the ranges of the successful status codes.
+	SuccessStatuses [][2]int
+}
+
+func (c HTTPRequestConfig) isSuccess(status int) bool {
+	for _, r := range c.SuccessStatuses {
+		if r[0] <= status && status <= r[1] {
+			return true
+		}
+	}
+	return false
+}
+
+func (a *HTTPRequestAction) collectConfig(attrs map[string]string) (HTTPRequestConfig, error) {
+	cfg := HTTPRequestConfig{}
+
+	cfg.URL = attrs["url"]
+	u, err := url.Parse(cfg.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return HTTPRequestConfig{}, errors.Newf("invalid url %q", cfg.URL)
+	}
+
+	cfg.Method = http.MethodPost
+	if method, ok := attrs["method"]; ok {
+		cfg.Method = strings.ToUpper(method)
+	}
+
+	headersCfg, ok := attrs["headers"]
+	if ok {
+		if err := yaml.Unmarshal([]byte(headersCfg), &cfg.Headers); err != nil {
+			return HTTPRequestConfig{}, errors.Wrap(err, "invalid headers")
+		}
+	}
+
+	if body, ok := attrs["body"]; ok {
+		cfg.Body = []byte(body)
+	}
+
+	dryRunCfg, ok := attrs["dry_run"]
+	if ok {
+		dryRun, err := strconv.ParseBool(dryRunCfg)
+		if err != nil {
+			return HTTPRequestConfig{}, errors.Wrap(err, "invalid dry_run")
+		}
+		cfg.DryRun = dryRun
+	}
+
+	cfg.Timeout = 10 * time.Second
+	timeoutCfg, ok := attrs["timeout"]
+	if ok {
+		timeout, err := time.ParseDuration(timeoutCfg)
+		if err != nil {
+			return HTTPRequestConfig{}, errors.Wrap(err, "invalid timeout")
+		}
+		cfg.Timeout = timeout
+	}
+
+	cfg.TLSConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+	if caFile, ok := attrs["ca_file"]; ok {
+		b, err := os.ReadFile(caFile)
+		if err != nil {
+			return HTTPRequestConfig{}, errors.Wrap(err, "failed to read ca_file")
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(b) {
+			return HTTPRequestConfig{}, errors.Newf("no certificate is found in ca_file %q", caFile)
+		}
+		cfg.TLSConfig.RootCAs = pool
+	}
+	insecureCfg, ok := attrs["insecure_skip_verify"]
+	if ok {
+		insecure, err := strconv.ParseBool(insecureCfg)
+		if err != nil {
+			return HTTPRequestConfig{}, errors.Wrap(err, "invalid insecure_skip_verify")
+		}
+		cfg.TLSConfig.InsecureSkipVerify = insecure
+	}
+
+	auth := config.SecretSource{File: attrs["auth_file"]}
+	if name, ok := attrs["auth_secret_name"]; ok {
+		auth.SecretRef = &config.SecretKeyRef{
+			Namespace: attrs["auth_secret_namespace"],
+			Name:      name,
+			Key:       attrs["auth_secret_key"],
+		}
+	}
+	if auth.File != "" || auth.SecretRef != nil {
+		if err := auth.ValidateAndDefault(); err != nil {
+			return HTTPRequestConfig{}, errors.Wrap(err, "invalid auth")
+		}
+		cfg.Auth = &auth
+	}
+	cfg.AuthHeader = "Authorization"
+	if authHeader, ok := attrs["auth_header"]; ok {
+		cfg.AuthHeader = authHeader
+	}
+	cfg.AuthPrefix = "Bearer "
+	if authPrefix, ok := attrs["auth_prefix"]; ok {
+		cfg.AuthPrefix = authPrefix
+	}
+
+	cfg.SuccessStatuses = [][2]int{{200, 299}}
+	successStatusCfg, ok := attrs["success_status"]
+	if ok {
+		statuses, err := parseStatusRanges(successStatusCfg)
+		if err != nil {
+			return HTTPRequestConfig{}, errors.Wrapf(err, "invalid success_status %q", successStatusCfg)
+		}
+		cfg.SuccessStatuses = statuses
+	}
+
+	return cfg, nil
+}
+
+// parseStatusRanges parses the comma-separated status codes and ranges like "200-299,404".
+func parseStatusRanges(s string) ([][2]int, error) {
+	ranges := [][2]int{}
+	for _, part := range strings.Split(s, ",") {
+		lower, upper, found := strings.Cut(strings.TrimSpace(part), "-")
+		if !found {
+			upper = lower
+		}
+
+		from, err := strconv.Atoi(lower)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		to, err := strconv.Atoi(upper)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		if from > to {
+			return nil, errors.Newf("invalid range %q", part)
+		}
+		ranges = append(ranges, [2]int{from, to})
+	}
+	return ranges, nil
+}
+
+func NewHTTPRequestAction(
+	logger *slog.Logger,
+	k8sClient client.Client,
+) Action {
+	actionLogger := logger.With(slog.String("action", "http-request"))
+	return &HTTPRequestAction{
+		logger:    actionLogger,
+		k8sClient: k8sClient,
+	}
+}
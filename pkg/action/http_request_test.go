@@ -0,0 +1,215 @@
+package action_test
+
+import (
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"log"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Drumato/amgate/pkg/action"
+	"github.com/Drumato/amgate/pkg/alertmanager"
+	"github.com/Drumato/amgate/pkg/dispatcher"
+	"github.com/stretchr/testify/assert"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+type recordedRequest struct {
+	method string
+	header http.Header
+	body   string
+}
+
+func TestHTTPRequestAction_Run(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	assert.NoError(t, os.WriteFile(tokenFile, []byte("s3cr3t\n"), 0o600))
+
+	tests := []struct {
+		name       string
+		status     int
+		delay      time.Duration
+		attrs      map[string]string
+		verifyFn   func(recordedRequest)
+		wantErr    bool
+		wantReason string
+	}{
+		{
+			name:   "default body",
+			status: http.StatusOK,
+			attrs:  map[string]string{},
+			verifyFn: func(req recordedRequest) {
+				assert.Equal(t, http.MethodPost, req.method)
+				assert.Equal(t, "application/json", req.header.Get("Content-Type"))
+
+				alert := dispatcher.DispatchAlert{}
+				assert.NoError(t, json.Unmarshal([]byte(req.body), &alert))
+				assert.Equal(t, "HighLatency", alert.Alert.Labels["alertname"])
+			},
+		},
+		{
+			name:   "method, headers and body",
+			status: http.StatusAccepted,
+			attrs: map[string]string{
+				"method":  "put",
+				"headers": "X-Runbook: restart-checkout\nContent-Type: text/plain\n",
+				"body":    "restart checkout",
+			},
+			verifyFn: func(req recordedRequest) {
+				assert.Equal(t, http.MethodPut, req.method)
+				assert.Equal(t, "restart-checkout", req.header.Get("X-Runbook"))
+				assert.Equal(t, "text/plain", req.header.Get("Content-Type"))
+				assert.Equal(t, "restart checkout", req.body)
+			},
+		},
+		{
+			name:   "auth header",
+			status: http.StatusOK,
+			attrs:  map[string]string{"auth_file": tokenFile},
+			verifyFn: func(req recordedRequest) {
+				assert.Equal(t, "Bearer s3cr3t", req.header.Get("Authorization"))
+			},
+		},
+		{
+			name:   "custom auth header",
+			status: http.StatusOK,
+			attrs:  map[string]string{"auth_file": tokenFile, "auth_header": "X-Api-Key", "auth_prefix": ""},
+			verifyFn: func(req recordedRequest) {
+				assert.Equal(t, "s3cr3t", req.header.Get("X-Api-Key"))
+				assert.Empty(t, req.header.Get("Authorization"))
+			},
+		},
+		{
+			name:       "server error",
+			status:     http.StatusServiceUnavailable,
+			attrs:      map[string]string{},
+			verifyFn:   func(req recordedRequest) {},
+			wantErr:    true,
+			wantReason: "ServiceUnavailable",
+		},
+		{
+			name:     "not a success status",
+			status:   http.StatusOK,
+			attrs:    map[string]string{"success_status": "201-204"},
+			verifyFn: func(req recordedRequest) {},
+			wantErr:  true,
+		},
+		{
+			name:     "custom success status",
+			status:   http.StatusNotFound,
+			attrs:    map[string]string{"success_status": "200-299,404"},
+			verifyFn: func(req recordedRequest) {},
+		},
+		{
+			name:     "timeout",
+			status:   http.StatusOK,
+			delay:    time.Second,
+			attrs:    map[string]string{"timeout": "50ms"},
+			verifyFn: func(req recordedRequest) {},
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests := make(chan recordedRequest, 1)
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				requests <- recordedRequest{method: r.Method, header: r.Header, body: string(body)}
+				select {
+				case <-time.After(tt.delay):
+				case <-r.Context().Done():
+				}
+				w.WriteHeader(tt.status)
+			}))
+			defer ts.Close()
+
+			logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+			a := action.NewHTTPRequestAction(logger, nil)
+
+			attrs := map[string]string{"url": ts.URL + "/hooks/runbook"}
+			for k, v := range tt.attrs {
+				attrs[k] = v
+			}
+
+			err := a.Run(t.Context(), dispatcher.DispatchResult{
+				Alert: dispatcher.DispatchAlert{
+					Alert: alertmanager.Alert{Labels: map[string]string{"alertname": "HighLatency"}},
+				},
+				Attrs: attrs,
+			})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Run() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantReason != "" {
+				assert.Equal(t, tt.wantReason, string(apierrors.ReasonForError(err)))
+			}
+
+			tt.verifyFn(<-requests)
+		})
+	}
+}
+
+func TestHTTPRequestAction_Run_TLS(t *testing.T) {
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	ts.Config.ErrorLog = log.New(io.Discard, "", 0)
+	ts.StartTLS()
+	defer ts.Close()
+
+	caFile := filepath.Join(t.TempDir(), "ca.crt")
+	assert.NoError(t, os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ts.Certificate().Raw}), 0o600))
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	a := action.NewHTTPRequestAction(logger, nil)
+
+	// the certificate of the server is not trusted.
+	err := a.Run(t.Context(), dispatcher.DispatchResult{
+		Attrs: map[string]string{"url": ts.URL},
+	})
+	assert.Error(t, err)
+
+	err = a.Run(t.Context(), dispatcher.DispatchResult{
+		Attrs: map[string]string{"url": ts.URL, "ca_file": caFile},
+	})
+	assert.NoError(t, err)
+
+	err = a.Run(t.Context(), dispatcher.DispatchResult{
+		Attrs: map[string]string{"url": ts.URL, "insecure_skip_verify": "true"},
+	})
+	assert.NoError(t, err)
+}
+
+func TestHTTPRequestAction_Run_CloseIdleConnections(t *testing.T) {
+	closed := atomic.Int32{}
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	ts.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateClosed {
+			closed.Add(1)
+		}
+	}
+	ts.Start()
+	defer ts.Close()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	a := action.NewHTTPRequestAction(logger, nil)
+	for range 3 {
+		assert.NoError(t, a.Run(t.Context(), dispatcher.DispatchResult{
+			Attrs: map[string]string{"url": ts.URL},
+		}))
+	}
+
+	// no keep-alive connection is left open by the runs.
+	assert.Eventually(t, func() bool { return closed.Load() == 3 }, time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,253 @@
+package action
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Drumato/amgate/pkg/dispatcher"
+	"github.com/cockroachdb/errors"
+	"github.com/samber/lo"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+)
+
+type K8sJobAction struct {
+	logger       *slog.Logger
+	k8sClient    client.Client
+	pollInterval time.Duration
+}
+
+func (a *K8sJobAction) Name() string {
+	return "k8s-job"
+}
+
+func (a *K8sJobAction) Run(ctx context.Context, result dispatcher.DispatchResult) error {
+	cfg, err := a.collectConfig(result.Attrs)
+	if err != nil {
+		return err
+	}
+
+	var job *batchv1.Job
+	if cfg.CronJob != "" {
+		job, err = a.jobFromCronJob(ctx, cfg)
+		if err != nil {
+			return err
+		}
+	} else {
+		job = cfg.Manifest.DeepCopy()
+		if cfg.Namespace != "" {
+			job.Namespace = cfg.Namespace
+		}
+		if job.Name == "" && job.GenerateName == "" {
+			return errors.New("manifest must have metadata.name or metadata.generateName")
+		}
+	}
+
+	env := alertEnv(result.Alert)
+	for i := range job.Spec.Template.Spec.InitContainers {
+		job.Spec.Template.Spec.InitContainers[i].Env = append(job.Spec.Template.Spec.InitContainers[i].Env, env...)
+	}
+	for i := range job.Spec.Template.Spec.Containers {
+		job.Spec.Template.Spec.Containers[i].Env = append(job.Spec.Template.Spec.Containers[i].Env, env...)
+	}
+
+	if cfg.DryRun {
+		// dry-run
+		a.logger.Info("dry-run", slog.String("namespace", job.Namespace), slog.String("name", lo.If(job.Name != "", job.Name).Else(job.GenerateName)))
+		return nil
+	}
+
+	if err := a.k8sClient.Create(ctx, job); err != nil {
+		return errors.WithStack(err)
+	}
+
+	logger := a.logger.With(slog.String("namespace", job.Namespace), slog.String("name", job.Name))
+	logger.InfoContext(ctx, "job created")
+	SetOutput(ctx, "job_name", job.Name)
+	if !cfg.Wait {
+		return nil
+	}
+
+	if err := a.waitForJob(ctx, client.ObjectKeyFromObject(job), cfg.Timeout); err != nil {
+		logger.ErrorContext(ctx, "job did not succeed", slog.String("error", err.Error()))
+		return err
+	}
+	logger.InfoContext(ctx, "job succeeded")
+	return nil
+}
+
+// jobFromCronJob creates a job from the jobTemplate of the CronJob like `kubectl create job --from=cronjob/...`.
+// https://github.com/kubernetes/kubectl/blob/fd89c3d1570b30935474a96cf42677d89faa2482/pkg/cmd/create/create_job.go#L264
+func (a *K8sJobAction) jobFromCronJob(ctx context.Context, cfg K8sJobConfig) (*batchv1.Job, error) {
+	cronJob := batchv1.CronJob{}
+	if err := a.k8sClient.Get(ctx, types.NamespacedName{
+		Namespace: cfg.Namespace,
+		Name:      cfg.CronJob,
+	}, &cronJob); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	annotations := map[string]string{"cronjob.kubernetes.io/instantiate": "manual"}
+	maps.Copy(annotations, cronJob.Spec.JobTemplate.Annotations)
+
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			// the jobs are created for every notification, so their names are generated.
+			GenerateName: cronJob.Name + "-amgate-",
+			Namespace:    cronJob.Namespace,
+			Labels:       cronJob.Spec.JobTemplate.Labels,
+			Annotations:  annotations,
+			OwnerReferences: []metav1.OwnerReference{
+				{
+					APIVersion: batchv1.SchemeGroupVersion.String(),
+					Kind:       "CronJob",
+					Name:       cronJob.Name,
+					UID:        cronJob.UID,
+					Controller: ptr.To(true),
+				},
+			},
+		},
+		Spec: *cronJob.Spec.JobTemplate.Spec.DeepCopy(),
+	}, nil
+}
+
+// waitForJob polls the conditions of the job until it completes or fails.
+func (a *K8sJobAction) waitForJob(ctx context.Context, key types.NamespacedName, timeout time.Duration) error {
+	return pollUntilDone(ctx, a.pollInterval, timeout, fmt.Sprintf("job %s", key), func(ctx context.Context) (bool, error) {
+		job := batchv1.Job{}
+		if err := a.k8sClient.Get(ctx, key, &job); err != nil {
+			return false, errors.WithStack(err)
+		}
+
+		for _, cond := range job.Status.Conditions {
+			if cond.Status != corev1.ConditionTrue {
+				continue
+			}
+			switch cond.Type {
+			case batchv1.JobComplete:
+				return true, nil
+			case batchv1.JobFailed:
+				return false, errors.Newf("job %s failed: %s", key, cond.Message)
+			}
+		}
+		return false, nil
+	})
+}
+
+// alertEnv converts the labels and annotations of the alert into the environment variables
+// like AMGATE_LABEL_ALERTNAME and AMGATE_ANNOTATION_SUMMARY.
+func alertEnv(alert dispatcher.DispatchAlert) []corev1.EnvVar {
+	env := []corev1.EnvVar{
+		{Name: "AMGATE_STATUS", Value: alert.Alert.Status},
+	}
+	for _, k := range slices.Sorted(maps.Keys(alert.Alert.Labels)) {
+		env = append(env, corev1.EnvVar{Name: "AMGATE_LABEL_" + envName(k), Value: alert.Alert.Labels[k]})
+	}
+	for _, k := range slices.Sorted(maps.Keys(alert.Alert.Annotations)) {
+		env = append(env, corev1.EnvVar{Name: "AMGATE_ANNOTATION_" + envName(k), Value: alert.Alert.Annotations[k]})
+	}
+	return env
+}
+
+// envName converts the label name into the upper-cased environment variable name.
+func envName(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case 'a' <= r && r <= 'z':
+			return r - 'a' + 'A'
+		case 'A' <= r && r <= 'Z', '0' <= r && r <= '9':
+			return r
+		default:
+			return '_'
+		}
+	}, name)
+}
+
+type K8sJobConfig struct {
+	DryRun bool
+
+	Namespace string
+	// CronJob is the name of the CronJob whose jobTemplate is used.
+	CronJob string
+	// Manifest is the inline Job manifest, exclusive with CronJob.
+	Manifest *batchv1.Job
+
+	// Wait waits until the job completes, and fails if the job fails.
+	Wait bool
+	// Timeout is the timeout of waiting.
+	// 0 waits until server.queue.actionTimeout.
+	Timeout time.Duration
+}
+
+func (a *K8sJobAction) collectConfig(attrs map[string]string) (K8sJobConfig, error) {
+	cfg := K8sJobConfig{}
+	cfg.Namespace = attrs["namespace"]
+	cfg.CronJob = attrs["cronjob"]
+
+	manifestCfg, ok := attrs["manifest"]
+	switch {
+	case ok && cfg.CronJob != "":
+		return K8sJobConfig{}, errors.New("cronjob and manifest are exclusive")
+	case ok:
+		job := batchv1.Job{}
+		if err := yaml.UnmarshalStrict([]byte(manifestCfg), &job); err != nil {
+			return K8sJobConfig{}, errors.Wrap(err, "invalid manifest")
+		}
+		cfg.Manifest = &job
+	case cfg.CronJob == "":
+		return K8sJobConfig{}, errors.New("cronjob or manifest is required")
+	}
+
+	dryRunCfg, ok := attrs["dry_run"]
+	if ok {
+		dryRun, err := strconv.ParseBool(dryRunCfg)
+		if err != nil {
+			return K8sJobConfig{}, errors.Wrap(err, "invalid dry_run")
+		}
+		cfg.DryRun = dryRun
+	}
+
+	waitCfg, ok := attrs["wait"]
+	if ok {
+		wait, err := strconv.ParseBool(waitCfg)
+		if err != nil {
+			return K8sJobConfig{}, errors.Wrap(err, "invalid wait")
+		}
+		cfg.Wait = wait
+	}
+
+	// waits until server.queue.actionTimeout by default.
+	timeoutCfg, ok := attrs["timeout"]
+	if ok {
+		timeout, err := time.ParseDuration(timeoutCfg)
+		if err != nil {
+			return K8sJobConfig{}, errors.Wrap(err, "invalid timeout")
+		}
+		cfg.Timeout = timeout
+	}
+
+	return cfg, nil
+}
+
+func NewK8sJobAction(
+	logger *slog.Logger,
+	k8sClient client.Client,
+) Action {
+	actionLogger := logger.With(slog.String("action", "k8s-job"))
+	return &K8sJobAction{
+		logger:       actionLogger,
+		k8sClient:    k8sClient,
+		pollInterval: 2 * time.Second,
+	}
+}
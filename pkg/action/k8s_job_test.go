@@ -0,0 +1,180 @@
+package action_test
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/Drumato/amgate/pkg/action"
+	"github.com/Drumato/amgate/pkg/alertmanager"
+	"github.com/Drumato/amgate/pkg/dispatcher"
+	"github.com/stretchr/testify/assert"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+)
+
+const testJobManifest = `
+apiVersion: batch/v1
+kind: Job
+metadata:
+  generateName: diagnose-
+  namespace: default
+spec:
+  template:
+    spec:
+      restartPolicy: Never
+      containers:
+        - name: diagnose
+          image: busybox
+`
+
+// completeJobs sets the condition to the created jobs as if the job controller ran them.
+func completeJobs(condition batchv1.JobConditionType) interceptor.Funcs {
+	return interceptor.Funcs{
+		Create: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+			if job, ok := obj.(*batchv1.Job); ok && condition != "" {
+				job.Status.Conditions = []batchv1.JobCondition{
+					{Type: condition, Status: corev1.ConditionTrue, Message: "test"},
+				}
+			}
+			return c.Create(ctx, obj, opts...)
+		},
+	}
+}
+
+func TestK8sJobAction_Run(t *testing.T) {
+	tests := []struct {
+		name      string
+		condition batchv1.JobConditionType
+		attrs     map[string]string
+		wantJobs  int
+		wantErr   bool
+	}{
+		{
+			name:     "from cronjob",
+			attrs:    map[string]string{"namespace": "default", "cronjob": "diagnose"},
+			wantJobs: 1,
+		},
+		{
+			name:     "from manifest",
+			attrs:    map[string]string{"manifest": testJobManifest},
+			wantJobs: 1,
+		},
+		{
+			name:      "wait succeeded",
+			condition: batchv1.JobComplete,
+			attrs:     map[string]string{"namespace": "default", "cronjob": "diagnose", "wait": "true", "timeout": "1s"},
+			wantJobs:  1,
+		},
+		{
+			name:      "wait failed",
+			condition: batchv1.JobFailed,
+			attrs:     map[string]string{"namespace": "default", "cronjob": "diagnose", "wait": "true", "timeout": "1m"},
+			wantJobs:  1,
+			wantErr:   true,
+		},
+		{
+			name:     "wait timeout",
+			attrs:    map[string]string{"namespace": "default", "cronjob": "diagnose", "wait": "true", "timeout": "100ms"},
+			wantJobs: 1,
+			wantErr:  true,
+		},
+		{
+			name:     "dry run",
+			attrs:    map[string]string{"namespace": "default", "cronjob": "diagnose", "dry_run": "true"},
+			wantJobs: 0,
+		},
+		{
+			name:     "cronjob not found",
+			attrs:    map[string]string{"namespace": "default", "cronjob": "unknown"},
+			wantJobs: 0,
+			wantErr:  true,
+		},
+		{
+			name:     "cronjob and manifest",
+			attrs:    map[string]string{"namespace": "default", "cronjob": "diagnose", "manifest": testJobManifest},
+			wantJobs: 0,
+			wantErr:  true,
+		},
+		{
+			name:     "invalid manifest",
+			attrs:    map[string]string{"manifest": "spec: {unknown: true}"},
+			wantJobs: 0,
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+			c := fake.NewClientBuilder().
+				WithObjects(&batchv1.CronJob{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "diagnose",
+						Namespace: "default",
+						UID:       "cronjob-uid",
+					},
+					Spec: batchv1.CronJobSpec{
+						JobTemplate: batchv1.JobTemplateSpec{
+							Spec: batchv1.JobSpec{
+								Template: corev1.PodTemplateSpec{
+									Spec: corev1.PodSpec{
+										Containers: []corev1.Container{{Name: "diagnose", Image: "busybox"}},
+									},
+								},
+							},
+						},
+					},
+				}).
+				WithInterceptorFuncs(completeJobs(tt.condition)).
+				Build()
+			a := action.NewK8sJobAction(logger, c)
+
+			err := a.Run(t.Context(), dispatcher.DispatchResult{
+				Alert: dispatcher.DispatchAlert{
+					Alert: alertmanager.Alert{
+						Status:      "firing",
+						Labels:      map[string]string{"alertname": "HighLatency", "app.kubernetes.io/name": "checkout"},
+						Annotations: map[string]string{"summary": "latency is high"},
+					},
+				},
+				Attrs: tt.attrs,
+			})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Run() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			jobs := &batchv1.JobList{}
+			assert.NoError(t, c.List(t.Context(), jobs))
+			assert.Len(t, jobs.Items, tt.wantJobs)
+			for _, job := range jobs.Items {
+				assert.Equal(t, []corev1.EnvVar{
+					{Name: "AMGATE_STATUS", Value: "firing"},
+					{Name: "AMGATE_LABEL_ALERTNAME", Value: "HighLatency"},
+					{Name: "AMGATE_LABEL_APP_KUBERNETES_IO_NAME", Value: "checkout"},
+					{Name: "AMGATE_ANNOTATION_SUMMARY", Value: "latency is high"},
+				}, job.Spec.Template.Spec.Containers[0].Env)
+			}
+		})
+	}
+}
+
+func TestK8sJobAction_Run_ActionTimeout(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	a := action.NewK8sJobAction(logger, fake.NewClientBuilder().Build())
+
+	// the deadline of the context is server.queue.actionTimeout.
+	ctx, cancel := context.WithTimeout(t.Context(), 500*time.Millisecond)
+	defer cancel()
+	err := a.Run(ctx, dispatcher.DispatchResult{
+		Attrs: map[string]string{"manifest": testJobManifest, "wait": "true"},
+	})
+	assert.ErrorContains(t, err, "did not complete before the action timeout")
+}
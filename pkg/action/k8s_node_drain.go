@@ -0,0 +1,277 @@
+package action
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+	"sync"
+
+	"github.com/Drumato/amgate/pkg/dispatcher"
+	"github.com/cockroachdb/errors"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// cordonedLabel marks the nodes cordoned by amgate,
+	// so that only they are counted by the cap and uncordoned on the resolved notification.
+	cordonedLabel = "amgate.drumato.com/cordoned"
+	// mirrorPodAnnotation marks the static pods managed by the kubelet.
+	mirrorPodAnnotation = "kubernetes.io/config.mirror"
+)
+
+type K8sNodeDrainAction struct {
+	logger    *slog.Logger
+	k8sClient client.Client
+	// mu serializes the cap check and the cordon across the workers.
+	mu sync.Mutex
+}
+
+func (a *K8sNodeDrainAction) Name() string {
+	return "k8s-node-drain"
+}
+
+func (a *K8sNodeDrainAction) Run(ctx context.Context, result dispatcher.DispatchResult) error {
+	cfg, err := a.collectConfig(result.Attrs, result.Alert.Alert.Labels)
+	if err != nil {
+		return err
+	}
+
+	node := corev1.Node{}
+	if err := a.k8sClient.Get(ctx, types.NamespacedName{Name: cfg.Name}, &node); err != nil {
+		return errors.WithStack(err)
+	}
+
+	logger := a.logger.With(slog.String("node", cfg.Name))
+	if result.Alert.Alert.Status == "resolved" {
+		if !cfg.UncordonOnResolved {
+			// never drain a node on the resolved notification.
+			return nil
+		}
+		return a.uncordon(ctx, logger, &node, cfg.DryRun)
+	}
+
+	// like `kubectl drain`, the action refuses to drain a node with unmanaged pods unless forced.
+	// https://github.com/kubernetes/kubectl/blob/fd89c3d1570b30935474a96cf42677d89faa2482/pkg/drain/filters.go
+	pods, err := a.podsToEvict(ctx, cfg)
+	if err != nil {
+		return err
+	}
+
+	if err := a.cordon(ctx, logger, &node, cfg); err != nil {
+		return err
+	}
+
+	var errs error
+	for i := range pods {
+		pod := &pods[i]
+		if cfg.DryRun {
+			// dry-run
+			logger.Info("dry-run", slog.String("namespace", pod.Namespace), slog.String("pod", pod.Name))
+			continue
+		}
+
+		// the eviction is rejected with 429 TooManyRequests if it violates a PodDisruptionBudget,
+		// so the remaining pods are evicted by the retry of the action.
+		eviction := &policyv1.Eviction{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: pod.Namespace,
+				Name:      pod.Name,
+			},
+			DeleteOptions: &metav1.DeleteOptions{GracePeriodSeconds: cfg.GracePeriodSeconds},
+		}
+		if err := a.k8sClient.SubResource("eviction").Create(ctx, pod, eviction); err != nil {
+			errs = errors.CombineErrors(errs, errors.Wrapf(err, "failed to evict pod %s/%s", pod.Namespace, pod.Name))
+			continue
+		}
+		logger.InfoContext(ctx, "pod evicted", slog.String("namespace", pod.Namespace), slog.String("pod", pod.Name))
+	}
+
+	return errs
+}
+
+// podsToEvict lists the pods on the node except DaemonSet pods, mirror pods and terminated pods.
+func (a *K8sNodeDrainAction) podsToEvict(ctx context.Context, cfg K8sNodeDrainConfig) ([]corev1.Pod, error) {
+	podList := corev1.PodList{}
+	if err := a.k8sClient.List(ctx, &podList, client.MatchingFields{"spec.nodeName": cfg.Name}); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	pods := []corev1.Pod{}
+	for _, pod := range podList.Items {
+		if _, ok := pod.Annotations[mirrorPodAnnotation]; ok {
+			continue
+		}
+		if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+			continue
+		}
+
+		controller := metav1.GetControllerOf(&pod)
+		if controller != nil && controller.Kind == "DaemonSet" {
+			continue
+		}
+		if controller == nil && !cfg.Force {
+			return nil, errors.Newf("pod %s/%s is not managed by any controller, set force to evict it", pod.Namespace, pod.Name)
+		}
+
+		pods = append(pods, pod)
+	}
+
+	return pods, nil
+}
+
+// cordon marks the node unschedulable unless the cap of the nodes cordoned by amgate is reached.
+func (a *K8sNodeDrainAction) cordon(ctx context.Context, logger *slog.Logger, node *corev1.Node, cfg K8sNodeDrainConfig) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if node.Labels[cordonedLabel] == "true" {
+		// retried or notified repeatedly.
+		return nil
+	}
+	if node.Spec.Unschedulable {
+		// cordoned by someone else, so it is neither counted nor uncordoned by amgate.
+		return nil
+	}
+
+	cordoned := corev1.NodeList{}
+	if err := a.k8sClient.List(ctx, &cordoned, client.MatchingLabels{cordonedLabel: "true"}); err != nil {
+		return errors.WithStack(err)
+	}
+	if len(cordoned.Items) >= cfg.MaxCordonedNodes {
+		return errors.Newf("%d nodes are already cordoned by amgate, reaching max_cordoned_nodes %d", len(cordoned.Items), cfg.MaxCordonedNodes)
+	}
+
+	if cfg.DryRun {
+		// dry-run
+		logger.Info("dry-run", slog.String("operation", "cordon"))
+		return nil
+	}
+
+	patch := client.MergeFrom(node.DeepCopy())
+	if node.Labels == nil {
+		node.Labels = map[string]string{}
+	}
+	node.Labels[cordonedLabel] = "true"
+	node.Spec.Unschedulable = true
+	if err := a.k8sClient.Patch(ctx, node, patch); err != nil {
+		return errors.WithStack(err)
+	}
+
+	logger.InfoContext(ctx, "node cordoned")
+	return nil
+}
+
+// uncordon marks the node schedulable if it was cordoned by amgate.
+func (a *K8sNodeDrainAction) uncordon(ctx context.Context, logger *slog.Logger, node *corev1.Node, dryRun bool) error {
+	if node.Labels[cordonedLabel] != "true" {
+		logger.InfoContext(ctx, "node is not cordoned by amgate")
+		return nil
+	}
+
+	if dryRun {
+		// dry-run
+		logger.Info("dry-run", slog.String("operation", "uncordon"))
+		return nil
+	}
+
+	patch := client.MergeFrom(node.DeepCopy())
+	delete(node.Labels, cordonedLabel)
+	node.Spec.Unschedulable = false
+	if err := a.k8sClient.Patch(ctx, node, patch); err != nil {
+		return errors.WithStack(err)
+	}
+
+	logger.InfoContext(ctx, "node uncordoned")
+	return nil
+}
+
+type K8sNodeDrainConfig struct {
+	DryRun bool
+
+	// Name defaults to the `node` label of the alert.
+	Name string
+
+	// Force evicts the pods not managed by any controller.
+	Force bool
+	// GracePeriodSeconds overrides the terminationGracePeriodSeconds of the pods.
+	GracePeriodSeconds *int64
+	// MaxCordonedNodes is the cluster-wide cap of the nodes cordoned by amgate at once.
+	MaxCordonedNodes int
+	// UncordonOnResolved uncordons the node when the alert is resolved.
+	UncordonOnResolved bool
+}
+
+func (a *K8sNodeDrainAction) collectConfig(attrs map[string]string, labels map[string]string) (K8sNodeDrainConfig, error) {
+	cfg := K8sNodeDrainConfig{}
+	cfg.Name = attrs["name"]
+	if cfg.Name == "" {
+		cfg.Name = labels["node"]
+	}
+	if cfg.Name == "" {
+		return K8sNodeDrainConfig{}, errors.New("name is required, or the alert must have node label")
+	}
+
+	dryRunCfg, ok := attrs["dry_run"]
+	if ok {
+		dryRun, err := strconv.ParseBool(dryRunCfg)
+		if err != nil {
+			return K8sNodeDrainConfig{}, errors.Wrap(err, "invalid dry_run")
+		}
+		cfg.DryRun = dryRun
+	}
+
+	forceCfg, ok := attrs["force"]
+	if ok {
+		force, err := strconv.ParseBool(forceCfg)
+		if err != nil {
+			return K8sNodeDrainConfig{}, errors.Wrap(err, "invalid force")
+		}
+		cfg.Force = force
+	}
+
+	gracePeriodCfg, ok := attrs["grace_period"]
+	if ok {
+		gracePeriod, err := strconv.ParseInt(gracePeriodCfg, 10, 64)
+		if err != nil || gracePeriod < 0 {
+			return K8sNodeDrainConfig{}, errors.Newf("invalid grace_period %q", gracePeriodCfg)
+		}
+		cfg.GracePeriodSeconds = ptr.To(gracePeriod)
+	}
+
+	cfg.MaxCordonedNodes = 1
+	maxCordonedNodesCfg, ok := attrs["max_cordoned_nodes"]
+	if ok {
+		maxCordonedNodes, err := strconv.Atoi(maxCordonedNodesCfg)
+		if err != nil || maxCordonedNodes < 1 {
+			return K8sNodeDrainConfig{}, errors.Newf("invalid max_cordoned_nodes %q", maxCordonedNodesCfg)
+		}
+		cfg.MaxCordonedNodes = maxCordonedNodes
+	}
+
+	uncordonCfg, ok := attrs["uncordon_on_resolved"]
+	if ok {
+		uncordon, err := strconv.ParseBool(uncordonCfg)
+		if err != nil {
+			return K8sNodeDrainConfig{}, errors.Wrap(err, "invalid uncordon_on_resolved")
+		}
+		cfg.UncordonOnResolved = uncordon
+	}
+
+	return cfg, nil
+}
+
+func NewK8sNodeDrainAction(
+	logger *slog.Logger,
+	k8sClient client.Client,
+) Action {
+	actionLogger := logger.With(slog.String("action", "k8s-node-drain"))
+	return &K8sNodeDrainAction{
+		logger:    actionLogger,
+		k8sClient: k8sClient,
+	}
+}
@@ -0,0 +1,196 @@
+package action_test
+
+import (
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/Drumato/amgate/pkg/action"
+	"github.com/Drumato/amgate/pkg/alertmanager"
+	"github.com/Drumato/amgate/pkg/dispatcher"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func newDrainPod(name, node, ownerKind string, annotations map[string]string, phase corev1.PodPhase) *corev1.Pod {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Namespace:   "default",
+			Annotations: annotations,
+		},
+		Spec:   corev1.PodSpec{NodeName: node},
+		Status: corev1.PodStatus{Phase: phase},
+	}
+	if ownerKind != "" {
+		pod.OwnerReferences = []metav1.OwnerReference{
+			{APIVersion: "apps/v1", Kind: ownerKind, Name: "owner", UID: "owner-uid", Controller: ptr.To(true)},
+		}
+	}
+	return pod
+}
+
+func newDrainClient(objects ...client.Object) client.Client {
+	return fake.NewClientBuilder().
+		WithObjects(objects...).
+		WithIndex(&corev1.Pod{}, "spec.nodeName", func(obj client.Object) []string {
+			return []string{obj.(*corev1.Pod).Spec.NodeName}
+		}).
+		Build()
+}
+
+func TestK8sNodeDrainAction_Run(t *testing.T) {
+	tests := []struct {
+		name              string
+		objects           []client.Object
+		status            string
+		attrs             map[string]string
+		wantUnschedulable bool
+		wantPods          []string
+		wantErr           bool
+	}{
+		{
+			name: "drain",
+			objects: []client.Object{
+				newDrainPod("app", "node-1", "ReplicaSet", nil, corev1.PodRunning),
+				newDrainPod("daemon", "node-1", "DaemonSet", nil, corev1.PodRunning),
+				newDrainPod("static", "node-1", "", map[string]string{"kubernetes.io/config.mirror": "hash"}, corev1.PodRunning),
+				newDrainPod("completed", "node-1", "Job", nil, corev1.PodSucceeded),
+				newDrainPod("other", "node-2", "ReplicaSet", nil, corev1.PodRunning),
+			},
+			attrs:             map[string]string{},
+			wantUnschedulable: true,
+			wantPods:          []string{"completed", "daemon", "other", "static"},
+		},
+		{
+			name: "unmanaged pod",
+			objects: []client.Object{
+				newDrainPod("app", "node-1", "ReplicaSet", nil, corev1.PodRunning),
+				newDrainPod("bare", "node-1", "", nil, corev1.PodRunning),
+			},
+			attrs:             map[string]string{},
+			wantUnschedulable: false,
+			wantPods:          []string{"app", "bare"},
+			wantErr:           true,
+		},
+		{
+			name: "force unmanaged pod",
+			objects: []client.Object{
+				newDrainPod("app", "node-1", "ReplicaSet", nil, corev1.PodRunning),
+				newDrainPod("bare", "node-1", "", nil, corev1.PodRunning),
+			},
+			attrs:             map[string]string{"force": "true"},
+			wantUnschedulable: true,
+			wantPods:          []string{},
+		},
+		{
+			name: "max cordoned nodes",
+			objects: []client.Object{
+				&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-2", Labels: map[string]string{"amgate.drumato.com/cordoned": "true"}}, Spec: corev1.NodeSpec{Unschedulable: true}},
+				newDrainPod("app", "node-1", "ReplicaSet", nil, corev1.PodRunning),
+			},
+			attrs:             map[string]string{},
+			wantUnschedulable: false,
+			wantPods:          []string{"app"},
+			wantErr:           true,
+		},
+		{
+			name: "within max cordoned nodes",
+			objects: []client.Object{
+				&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-2", Labels: map[string]string{"amgate.drumato.com/cordoned": "true"}}, Spec: corev1.NodeSpec{Unschedulable: true}},
+				newDrainPod("app", "node-1", "ReplicaSet", nil, corev1.PodRunning),
+			},
+			attrs:             map[string]string{"max_cordoned_nodes": "2"},
+			wantUnschedulable: true,
+			wantPods:          []string{},
+		},
+		{
+			name: "dry run",
+			objects: []client.Object{
+				newDrainPod("app", "node-1", "ReplicaSet", nil, corev1.PodRunning),
+			},
+			attrs:             map[string]string{"dry_run": "true"},
+			wantUnschedulable: false,
+			wantPods:          []string{"app"},
+		},
+		{
+			name: "resolved without uncordon",
+			objects: []client.Object{
+				newDrainPod("app", "node-1", "ReplicaSet", nil, corev1.PodRunning),
+			},
+			status:            "resolved",
+			attrs:             map[string]string{},
+			wantUnschedulable: false,
+			wantPods:          []string{"app"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+			objects := append([]client.Object{
+				&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1"}},
+			}, tt.objects...)
+			c := newDrainClient(objects...)
+			a := action.NewK8sNodeDrainAction(logger, c)
+
+			err := a.Run(t.Context(), dispatcher.DispatchResult{
+				Alert: dispatcher.DispatchAlert{
+					Alert: alertmanager.Alert{Status: tt.status, Labels: map[string]string{"node": "node-1"}},
+				},
+				Attrs: tt.attrs,
+			})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Run() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			node := &corev1.Node{}
+			assert.NoError(t, c.Get(t.Context(), client.ObjectKey{Name: "node-1"}, node))
+			assert.Equal(t, tt.wantUnschedulable, node.Spec.Unschedulable)
+
+			pods := &corev1.PodList{}
+			assert.NoError(t, c.List(t.Context(), pods))
+			got := []string{}
+			for _, pod := range pods.Items {
+				got = append(got, pod.Name)
+			}
+			assert.ElementsMatch(t, tt.wantPods, got)
+		})
+	}
+}
+
+func TestK8sNodeDrainAction_Run_Uncordon(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	c := newDrainClient(
+		&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1"}},
+		// cordoned by someone else.
+		&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-2"}, Spec: corev1.NodeSpec{Unschedulable: true}},
+	)
+	a := action.NewK8sNodeDrainAction(logger, c)
+	attrs := map[string]string{"uncordon_on_resolved": "true"}
+
+	for _, status := range []string{"firing", "resolved"} {
+		for _, node := range []string{"node-1", "node-2"} {
+			assert.NoError(t, a.Run(t.Context(), dispatcher.DispatchResult{
+				Alert: dispatcher.DispatchAlert{
+					Alert: alertmanager.Alert{Status: status, Labels: map[string]string{"node": node}},
+				},
+				Attrs: attrs,
+			}))
+		}
+	}
+
+	node1 := &corev1.Node{}
+	assert.NoError(t, c.Get(t.Context(), client.ObjectKey{Name: "node-1"}, node1))
+	assert.False(t, node1.Spec.Unschedulable)
+	assert.NotContains(t, node1.Labels, "amgate.drumato.com/cordoned")
+
+	node2 := &corev1.Node{}
+	assert.NoError(t, c.Get(t.Context(), client.ObjectKey{Name: "node-2"}, node2))
+	assert.True(t, node2.Spec.Unschedulable)
+}
@@ -0,0 +1,155 @@
+package action
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strconv"
+
+	"github.com/Drumato/amgate/pkg/dispatcher"
+	"github.com/cockroachdb/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+)
+
+// fieldManager is the field manager of the server-side apply by amgate.
+const fieldManager = "amgate"
+
+type K8sPatchAction struct {
+	logger    *slog.Logger
+	k8sClient client.Client
+}
+
+func (a *K8sPatchAction) Name() string {
+	return "k8s-patch"
+}
+
+func (a *K8sPatchAction) Run(ctx context.Context, result dispatcher.DispatchResult) error {
+	cfg, err := a.collectConfig(result.Attrs)
+	if err != nil {
+		return err
+	}
+
+	obj := &unstructured.Unstructured{}
+	obj.SetGroupVersionKind(cfg.GroupVersionKind)
+	obj.SetNamespace(cfg.Namespace)
+	obj.SetName(cfg.Name)
+
+	opts := []client.PatchOption{}
+	if cfg.DryRun {
+		// the patch is validated by the API server without being persisted.
+		opts = append(opts, client.DryRunAll)
+	}
+
+	var patch client.Patch
+	switch cfg.PatchType {
+	case "strategic":
+		patch = client.RawPatch(types.StrategicMergePatchType, cfg.Patch)
+	case "merge":
+		patch = client.RawPatch(types.MergePatchType, cfg.Patch)
+	case "json":
+		patch = client.RawPatch(types.JSONPatchType, cfg.Patch)
+	case "apply":
+		// the document of server-side apply is the object itself,
+		// and its apiVersion, kind and metadata may be omitted.
+		if err := json.Unmarshal(cfg.Patch, &obj.Object); err != nil {
+			return errors.Wrap(err, "invalid patch")
+		}
+		obj.SetGroupVersionKind(cfg.GroupVersionKind)
+		obj.SetNamespace(cfg.Namespace)
+		obj.SetName(cfg.Name)
+		patch = client.Apply
+		opts = append(opts, client.FieldOwner(fieldManager), client.ForceOwnership)
+	}
+
+	if err := a.k8sClient.Patch(ctx, obj, patch, opts...); err != nil {
+		return errors.WithStack(err)
+	}
+
+	a.logger.InfoContext(ctx, "patched",
+		slog.String("gvk", cfg.GroupVersionKind.String()),
+		slog.String("namespace", cfg.Namespace),
+		slog.String("name", cfg.Name),
+		slog.String("patchType", cfg.PatchType),
+		slog.Bool("dryRun", cfg.DryRun),
+	)
+	return nil
+}
+
+type K8sPatchConfig struct {
+	DryRun bool
+
+	GroupVersionKind schema.GroupVersionKind
+	Namespace        string
+	Name             string
+
+	// PatchType is one of strategic, merge, json and apply.
+	PatchType string
+	// Patch is the patch document converted into JSON.
+	Patch []byte
+}
+
+func (a *K8sPatchAction) collectConfig(attrs map[string]string) (K8sPatchConfig, error) {
+	cfg := K8sPatchConfig{}
+
+	gv, err := schema.ParseGroupVersion(attrs["api_version"])
+	if err != nil {
+		return K8sPatchConfig{}, errors.Wrap(err, "invalid api_version")
+	}
+	cfg.GroupVersionKind = gv.WithKind(attrs["kind"])
+	if cfg.GroupVersionKind.Version == "" || cfg.GroupVersionKind.Kind == "" {
+		return K8sPatchConfig{}, errors.New("api_version and kind are required")
+	}
+
+	cfg.Namespace = attrs["namespace"]
+	cfg.Name = attrs["name"]
+	if cfg.Name == "" {
+		return K8sPatchConfig{}, errors.New("name is required")
+	}
+
+	dryRunCfg, ok := attrs["dry_run"]
+	if ok {
+		dryRun, err := strconv.ParseBool(dryRunCfg)
+		if err != nil {
+			return K8sPatchConfig{}, errors.Wrap(err, "invalid dry_run")
+		}
+		cfg.DryRun = dryRun
+	}
+
+	cfg.PatchType = "strategic"
+	if patchType, ok := attrs["patch_type"]; ok {
+		cfg.PatchType = patchType
+	}
+	switch cfg.PatchType {
+	case "strategic", "merge", "json", "apply":
+	default:
+		return K8sPatchConfig{}, errors.Newf("unsupported patch_type %q", cfg.PatchType)
+	}
+
+	patchCfg, ok := attrs["patch"]
+	if !ok {
+		return K8sPatchConfig{}, errors.New("patch is required")
+	}
+	// the patch can be written in either YAML or JSON.
+	patch, err := yaml.YAMLToJSON([]byte(patchCfg))
+	if err != nil {
+		return K8sPatchConfig{}, errors.Wrap(err, "invalid patch")
+	}
+	cfg.Patch = patch
+
+	return cfg, nil
+}
+
+func NewK8sPatchAction(
+	logger *slog.Logger,
+	k8sClient client.Client,
+) Action {
+	actionLogger := logger.With(slog.String("action", "k8s-patch"))
+	return &K8sPatchAction{
+		logger:    actionLogger,
+		k8sClient: k8sClient,
+	}
+}
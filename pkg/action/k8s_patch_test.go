@@ -0,0 +1,140 @@
+package action_test
+
+import (
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/Drumato/amgate/pkg/action"
+	"github.com/Drumato/amgate/pkg/dispatcher"
+	"github.com/stretchr/testify/assert"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestK8sPatchAction_Run(t *testing.T) {
+	tests := []struct {
+		name     string
+		attrs    map[string]string
+		verifyFn func(client.Client)
+		wantErr  bool
+	}{
+		{
+			name: "merge patch configmap",
+			attrs: map[string]string{
+				"api_version": "v1",
+				"kind":        "ConfigMap",
+				"namespace":   "default",
+				"name":        "feature-flags",
+				"patch_type":  "merge",
+				"patch":       "data:\n  checkout-v2: \"false\"\n",
+			},
+			verifyFn: func(c client.Client) {
+				cm := &corev1.ConfigMap{}
+				assert.NoError(t, c.Get(t.Context(), client.ObjectKey{Name: "feature-flags", Namespace: "default"}, cm))
+				assert.Equal(t, map[string]string{"checkout-v2": "false", "search-v2": "true"}, cm.Data)
+			},
+		},
+		{
+			name: "json patch cronjob",
+			attrs: map[string]string{
+				"api_version": "batch/v1",
+				"kind":        "CronJob",
+				"namespace":   "default",
+				"name":        "batch",
+				"patch_type":  "json",
+				"patch":       `[{"op": "replace", "path": "/spec/suspend", "value": true}]`,
+			},
+			verifyFn: func(c client.Client) {
+				cronJob := &batchv1.CronJob{}
+				assert.NoError(t, c.Get(t.Context(), client.ObjectKey{Name: "batch", Namespace: "default"}, cronJob))
+				assert.True(t, *cronJob.Spec.Suspend)
+			},
+		},
+		{
+			name: "dry run",
+			attrs: map[string]string{
+				"api_version": "v1",
+				"kind":        "ConfigMap",
+				"namespace":   "default",
+				"name":        "feature-flags",
+				"patch_type":  "merge",
+				"patch":       `{"data": {"checkout-v2": "false"}}`,
+				"dry_run":     "true",
+			},
+			verifyFn: func(c client.Client) {
+				cm := &corev1.ConfigMap{}
+				assert.NoError(t, c.Get(t.Context(), client.ObjectKey{Name: "feature-flags", Namespace: "default"}, cm))
+				assert.Equal(t, "true", cm.Data["checkout-v2"])
+			},
+		},
+		{
+			name: "not found",
+			attrs: map[string]string{
+				"api_version": "v1",
+				"kind":        "ConfigMap",
+				"namespace":   "default",
+				"name":        "unknown",
+				"patch_type":  "merge",
+				"patch":       `{"data": {"checkout-v2": "false"}}`,
+			},
+			verifyFn: func(c client.Client) {},
+			wantErr:  true,
+		},
+		{
+			name: "unsupported patch type",
+			attrs: map[string]string{
+				"api_version": "v1",
+				"kind":        "ConfigMap",
+				"namespace":   "default",
+				"name":        "feature-flags",
+				"patch_type":  "replace",
+				"patch":       `{"data": {"checkout-v2": "false"}}`,
+			},
+			verifyFn: func(c client.Client) {},
+			wantErr:  true,
+		},
+		{
+			name: "no kind",
+			attrs: map[string]string{
+				"api_version": "v1",
+				"namespace":   "default",
+				"name":        "feature-flags",
+				"patch":       `{"data": {"checkout-v2": "false"}}`,
+			},
+			verifyFn: func(c client.Client) {},
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+			c := fake.NewClientBuilder().WithObjects(
+				&corev1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{Name: "feature-flags", Namespace: "default"},
+					Data:       map[string]string{"checkout-v2": "true", "search-v2": "true"},
+				},
+				&batchv1.CronJob{
+					ObjectMeta: metav1.ObjectMeta{Name: "batch", Namespace: "default"},
+					Spec:       batchv1.CronJobSpec{Schedule: "* * * * *", Suspend: ptr.To(false)},
+				},
+			).Build()
+			a := action.NewK8sPatchAction(logger, c)
+
+			err := a.Run(t.Context(), dispatcher.DispatchResult{
+				Attrs: tt.attrs,
+			})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Run() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			tt.verifyFn(c)
+		})
+	}
+}
@@ -0,0 +1,145 @@
+package action
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+
+	"github.com/Drumato/amgate/pkg/dispatcher"
+	"github.com/cockroachdb/errors"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+type K8sPodDeleteAction struct {
+	logger    *slog.Logger
+	k8sClient client.Client
+}
+
+func (a *K8sPodDeleteAction) Name() string {
+	return "k8s-pod-delete"
+}
+
+func (a *K8sPodDeleteAction) Run(ctx context.Context, result dispatcher.DispatchResult) error {
+	cfg, err := a.collectConfig(result.Attrs, result.Alert.Alert.Labels)
+	if err != nil {
+		return err
+	}
+
+	pod := corev1.Pod{}
+	if err := a.k8sClient.Get(ctx, types.NamespacedName{
+		Namespace: cfg.Namespace,
+		Name:      cfg.Name,
+	}, &pod); err != nil {
+		return errors.WithStack(err)
+	}
+
+	logger := a.logger.With(slog.String("namespace", cfg.Namespace), slog.String("name", cfg.Name), slog.String("mode", cfg.Mode))
+	if cfg.DryRun {
+		// dry-run
+		logger.Info("dry-run")
+		return nil
+	}
+
+	deleteOptions := &client.DeleteOptions{
+		GracePeriodSeconds: cfg.GracePeriodSeconds,
+		// avoid deleting the pod recreated with the same name, e.g. by a StatefulSet.
+		Preconditions: &metav1.Preconditions{UID: ptr.To(pod.UID)},
+	}
+
+	switch cfg.Mode {
+	case "delete":
+		if err := a.k8sClient.Delete(ctx, &pod, deleteOptions); err != nil {
+			return errors.WithStack(err)
+		}
+	case "evict":
+		// the eviction is rejected with 429 TooManyRequests if it violates a PodDisruptionBudget,
+		// so it is retried according to the retry policy of the action.
+		eviction := &policyv1.Eviction{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: pod.Namespace,
+				Name:      pod.Name,
+			},
+			DeleteOptions: deleteOptions.AsDeleteOptions(),
+		}
+		if err := a.k8sClient.SubResource("eviction").Create(ctx, &pod, eviction); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+
+	logger.InfoContext(ctx, "pod deleted")
+	return nil
+}
+
+type K8sPodDeleteConfig struct {
+	DryRun bool
+
+	// Namespace and Name default to the `namespace` and `pod` labels of the alert.
+	Namespace string
+	Name      string
+
+	// Mode is one of delete and evict.
+	Mode string
+	// GracePeriodSeconds overrides the terminationGracePeriodSeconds of the pod.
+	GracePeriodSeconds *int64
+}
+
+func (a *K8sPodDeleteAction) collectConfig(attrs map[string]string, labels map[string]string) (K8sPodDeleteConfig, error) {
+	cfg := K8sPodDeleteConfig{}
+	cfg.Namespace = attrs["namespace"]
+	if cfg.Namespace == "" {
+		cfg.Namespace = labels["namespace"]
+	}
+	cfg.Name = attrs["name"]
+	if cfg.Name == "" {
+		cfg.Name = labels["pod"]
+	}
+	if cfg.Namespace == "" || cfg.Name == "" {
+		return K8sPodDeleteConfig{}, errors.New("namespace and name are required, or the alert must have namespace and pod labels")
+	}
+
+	dryRunCfg, ok := attrs["dry_run"]
+	if ok {
+		dryRun, err := strconv.ParseBool(dryRunCfg)
+		if err != nil {
+			return K8sPodDeleteConfig{}, errors.Wrap(err, "invalid dry_run")
+		}
+		cfg.DryRun = dryRun
+	}
+
+	cfg.Mode = "delete"
+	if mode, ok := attrs["mode"]; ok {
+		cfg.Mode = mode
+	}
+	switch cfg.Mode {
+	case "delete", "evict":
+	default:
+		return K8sPodDeleteConfig{}, errors.Newf("unsupported mode %q", cfg.Mode)
+	}
+
+	gracePeriodCfg, ok := attrs["grace_period"]
+	if ok {
+		gracePeriod, err := strconv.ParseInt(gracePeriodCfg, 10, 64)
+		if err != nil || gracePeriod < 0 {
+			return K8sPodDeleteConfig{}, errors.Newf("invalid grace_period %q", gracePeriodCfg)
+		}
+		cfg.GracePeriodSeconds = ptr.To(gracePeriod)
+	}
+
+	return cfg, nil
+}
+
+func NewK8sPodDeleteAction(
+	logger *slog.Logger,
+	k8sClient client.Client,
+) Action {
+	actionLogger := logger.With(slog.String("action", "k8s-pod-delete"))
+	return &K8sPodDeleteAction{
+		logger:    actionLogger,
+		k8sClient: k8sClient,
+	}
+}
@@ -0,0 +1,111 @@
+package action_test
+
+import (
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/Drumato/amgate/pkg/action"
+	"github.com/Drumato/amgate/pkg/alertmanager"
+	"github.com/Drumato/amgate/pkg/dispatcher"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestK8sPodDeleteAction_Run(t *testing.T) {
+	tests := []struct {
+		name        string
+		labels      map[string]string
+		attrs       map[string]string
+		wantDeleted bool
+		wantErr     bool
+	}{
+		{
+			name:        "delete the pod of the alert",
+			labels:      map[string]string{"namespace": "default", "pod": "test-pod"},
+			attrs:       map[string]string{},
+			wantDeleted: true,
+		},
+		{
+			name:        "delete the pod of the attrs",
+			labels:      map[string]string{},
+			attrs:       map[string]string{"namespace": "default", "name": "test-pod", "grace_period": "0"},
+			wantDeleted: true,
+		},
+		{
+			name:        "evict",
+			labels:      map[string]string{"namespace": "default", "pod": "test-pod"},
+			attrs:       map[string]string{"mode": "evict", "grace_period": "30"},
+			wantDeleted: true,
+		},
+		{
+			name:        "dry run",
+			labels:      map[string]string{"namespace": "default", "pod": "test-pod"},
+			attrs:       map[string]string{"dry_run": "true"},
+			wantDeleted: false,
+		},
+		{
+			name:    "pod not found",
+			labels:  map[string]string{"namespace": "default", "pod": "other-pod"},
+			attrs:   map[string]string{},
+			wantErr: true,
+		},
+		{
+			name:    "no pod label",
+			labels:  map[string]string{"namespace": "default"},
+			attrs:   map[string]string{},
+			wantErr: true,
+		},
+		{
+			name:    "unsupported mode",
+			labels:  map[string]string{"namespace": "default", "pod": "test-pod"},
+			attrs:   map[string]string{"mode": "kill"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid grace period",
+			labels:  map[string]string{"namespace": "default", "pod": "test-pod"},
+			attrs:   map[string]string{"grace_period": "-1"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+			c := fake.NewClientBuilder().WithObjects(&corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-pod",
+					Namespace: "default",
+					UID:       "pod-uid",
+				},
+			}).Build()
+			a := action.NewK8sPodDeleteAction(logger, c)
+
+			err := a.Run(t.Context(), dispatcher.DispatchResult{
+				Alert: dispatcher.DispatchAlert{
+					Alert: alertmanager.Alert{Labels: tt.labels},
+				},
+				Attrs: tt.attrs,
+			})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Run() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			err = c.Get(t.Context(), client.ObjectKey{
+				Name:      "test-pod",
+				Namespace: "default",
+			}, &corev1.Pod{})
+			if tt.wantDeleted {
+				assert.True(t, apierrors.IsNotFound(err))
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,210 @@
+package action
+
+import (
+	"context"
+	"log/slog"
+	"maps"
+	"slices"
+	"strconv"
+
+	"github.com/Drumato/amgate/pkg/dispatcher"
+	"github.com/cockroachdb/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const revisionAnnotation = "deployment.kubernetes.io/revision"
+
+type K8sRollbackAction struct {
+	logger    *slog.Logger
+	k8sClient client.Client
+}
+
+func (a *K8sRollbackAction) Name() string {
+	return "k8s-rollback"
+}
+
+func (a *K8sRollbackAction) Run(ctx context.Context, result dispatcher.DispatchResult) error {
+	cfg, err := a.collectConfig(result.Attrs)
+	if err != nil {
+		return err
+	}
+
+	// rollback like `kubectl rollout undo`
+	// https://github.com/kubernetes/kubectl/blob/fd89c3d1570b30935474a96cf42677d89faa2482/pkg/polymorphichelpers/rollback.go#L97
+
+	deployment := appsv1.Deployment{}
+	if err := a.k8sClient.Get(ctx, types.NamespacedName{
+		Namespace: cfg.Namespace,
+		Name:      cfg.Name,
+	}, &deployment); err != nil {
+		return errors.WithStack(err)
+	}
+	if deployment.Spec.Paused {
+		return errors.Newf("deployment %s/%s is paused", cfg.Namespace, cfg.Name)
+	}
+
+	replicaSets, err := a.listRevisions(ctx, &deployment)
+	if err != nil {
+		return err
+	}
+
+	revisions := slices.Sorted(maps.Keys(replicaSets))
+	if len(revisions) == 0 {
+		return errors.Newf("no revision is found for deployment %s/%s", cfg.Namespace, cfg.Name)
+	}
+
+	current := revisions[len(revisions)-1]
+	if v, ok := deployment.Annotations[revisionAnnotation]; ok {
+		if revision, err := strconv.ParseInt(v, 10, 64); err == nil {
+			current = revision
+		}
+	}
+
+	target := cfg.ToRevision
+	if target == 0 {
+		// the previous revision of the current one.
+		for _, revision := range revisions {
+			if revision < current {
+				target = revision
+			}
+		}
+		if target == 0 {
+			return errors.Newf("no previous revision is found for deployment %s/%s", cfg.Namespace, cfg.Name)
+		}
+	}
+	if target == current {
+		return errors.Newf("revision %d is already the current revision", target)
+	}
+
+	rs, ok := replicaSets[target]
+	if !ok {
+		return errors.Newf("revision %d is not found for deployment %s/%s", target, cfg.Namespace, cfg.Name)
+	}
+
+	// the number of the revisions between the target and the current one.
+	back := 0
+	for _, revision := range revisions {
+		if target <= revision && revision < current {
+			back++
+		}
+	}
+	if back > cfg.MaxRevisionsBack {
+		return errors.Newf("revision %d is %d revisions back from the current revision %d, exceeding max_revisions_back %d", target, back, current, cfg.MaxRevisionsBack)
+	}
+
+	if cfg.DryRun {
+		// dry-run
+		a.logger.Info("dry-run", slog.String("namespace", cfg.Namespace), slog.String("name", cfg.Name), slog.Int64("fromRevision", current), slog.Int64("toRevision", target))
+		return nil
+	}
+
+	patch := client.MergeFrom(deployment.DeepCopy())
+	template := rs.Spec.Template.DeepCopy()
+	delete(template.Labels, appsv1.DefaultDeploymentUniqueLabelKey)
+	deployment.Spec.Template = *template
+
+	if err := a.k8sClient.Patch(ctx, &deployment, patch); err != nil {
+		return errors.WithStack(err)
+	}
+
+	a.logger.InfoContext(ctx, "rolled back", slog.String("namespace", cfg.Namespace), slog.String("name", cfg.Name), slog.Int64("fromRevision", current), slog.Int64("toRevision", target))
+	return nil
+}
+
+// listRevisions returns the ReplicaSets controlled by the deployment keyed by their revision.
+func (a *K8sRollbackAction) listRevisions(ctx context.Context, deployment *appsv1.Deployment) (map[int64]*appsv1.ReplicaSet, error) {
+	selector, err := metav1.LabelSelectorAsSelector(deployment.Spec.Selector)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	rsList := appsv1.ReplicaSetList{}
+	if err := a.k8sClient.List(ctx, &rsList,
+		client.InNamespace(deployment.Namespace),
+		client.MatchingLabelsSelector{Selector: selector},
+	); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	replicaSets := map[int64]*appsv1.ReplicaSet{}
+	for i := range rsList.Items {
+		rs := &rsList.Items[i]
+		if !metav1.IsControlledBy(rs, deployment) {
+			continue
+		}
+
+		revision, err := strconv.ParseInt(rs.Annotations[revisionAnnotation], 10, 64)
+		if err != nil {
+			continue
+		}
+		replicaSets[revision] = rs
+	}
+
+	return replicaSets, nil
+}
+
+type K8sRollbackConfig struct {
+	DryRun bool
+
+	Namespace string
+	Name      string
+
+	// ToRevision is the revision to roll back to.
+	// 0 means the previous revision.
+	ToRevision int64
+	// MaxRevisionsBack is the maximum number of the revisions that the action may roll back.
+	MaxRevisionsBack int
+}
+
+func (a *K8sRollbackAction) collectConfig(attrs map[string]string) (K8sRollbackConfig, error) {
+	cfg := K8sRollbackConfig{}
+	cfg.Namespace = attrs["namespace"]
+	cfg.Name = attrs["name"]
+	if cfg.Name == "" {
+		return K8sRollbackConfig{}, errors.New("name is required")
+	}
+
+	dryRunCfg, ok := attrs["dry_run"]
+	if ok {
+		dryRun, err := strconv.ParseBool(dryRunCfg)
+		if err != nil {
+			return K8sRollbackConfig{}, errors.Wrap(err, "invalid dry_run")
+		}
+		cfg.DryRun = dryRun
+	}
+
+	toRevisionCfg, ok := attrs["to_revision"]
+	if ok {
+		toRevision, err := strconv.ParseInt(toRevisionCfg, 10, 64)
+		if err != nil || toRevision < 0 {
+			return K8sRollbackConfig{}, errors.Newf("invalid to_revision %q", toRevisionCfg)
+		}
+		cfg.ToRevision = toRevision
+	}
+
+	cfg.MaxRevisionsBack = 1
+	maxRevisionsBackCfg, ok := attrs["max_revisions_back"]
+	if ok {
+		maxRevisionsBack, err := strconv.Atoi(maxRevisionsBackCfg)
+		if err != nil || maxRevisionsBack < 1 {
+			return K8sRollbackConfig{}, errors.Newf("invalid max_revisions_back %q", maxRevisionsBackCfg)
+		}
+		cfg.MaxRevisionsBack = maxRevisionsBack
+	}
+
+	return cfg, nil
+}
+
+func NewK8sRollbackAction(
+	logger *slog.Logger,
+	k8sClient client.Client,
+) Action {
+	actionLogger := logger.With(slog.String("action", "k8s-rollback"))
+	return &K8sRollbackAction{
+		logger:    actionLogger,
+		k8sClient: k8sClient,
+	}
+}
@@ -0,0 +1,154 @@
+package action_test
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/Drumato/amgate/pkg/action"
+	"github.com/Drumato/amgate/pkg/dispatcher"
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// newRolloutHistory creates a deployment whose current revision is the last one of images.
+func newRolloutHistory(t *testing.T, images ...string) client.Client {
+	t.Helper()
+
+	labels := map[string]string{"app": "test"}
+	template := func(image string) corev1.PodTemplateSpec {
+		return corev1.PodTemplateSpec{
+			ObjectMeta: metav1.ObjectMeta{Labels: labels},
+			Spec: corev1.PodSpec{
+				Containers: []corev1.Container{{Name: "app", Image: image}},
+			},
+		}
+	}
+
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "test-deployment",
+			Namespace:   "default",
+			UID:         "deployment-uid",
+			Annotations: map[string]string{"deployment.kubernetes.io/revision": fmt.Sprint(len(images))},
+		},
+		Spec: appsv1.DeploymentSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: labels},
+			Template: template(images[len(images)-1]),
+		},
+	}
+
+	objects := []client.Object{deployment}
+	for i, image := range images {
+		rsTemplate := template(image)
+		rsTemplate.Labels = map[string]string{"app": "test", "pod-template-hash": fmt.Sprint(i)}
+		objects = append(objects, &appsv1.ReplicaSet{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        fmt.Sprintf("test-deployment-%d", i),
+				Namespace:   "default",
+				Labels:      rsTemplate.Labels,
+				Annotations: map[string]string{"deployment.kubernetes.io/revision": fmt.Sprint(i + 1)},
+				OwnerReferences: []metav1.OwnerReference{
+					{
+						APIVersion: "apps/v1",
+						Kind:       "Deployment",
+						Name:       "test-deployment",
+						UID:        "deployment-uid",
+						Controller: ptr.To(true),
+					},
+				},
+			},
+			Spec: appsv1.ReplicaSetSpec{Template: rsTemplate},
+		})
+	}
+
+	return fake.NewClientBuilder().WithObjects(objects...).Build()
+}
+
+func TestK8sRollbackAction_Run(t *testing.T) {
+	tests := []struct {
+		name      string
+		images    []string
+		attrs     map[string]string
+		wantImage string
+		wantErr   bool
+	}{
+		{
+			name:      "previous revision",
+			images:    []string{"app:v1", "app:v2", "app:v3"},
+			attrs:     map[string]string{},
+			wantImage: "app:v2",
+		},
+		{
+			name:      "specific revision",
+			images:    []string{"app:v1", "app:v2", "app:v3"},
+			attrs:     map[string]string{"to_revision": "1", "max_revisions_back": "2"},
+			wantImage: "app:v1",
+		},
+		{
+			name:      "exceeds max revisions back",
+			images:    []string{"app:v1", "app:v2", "app:v3"},
+			attrs:     map[string]string{"to_revision": "1"},
+			wantImage: "app:v3",
+			wantErr:   true,
+		},
+		{
+			name:      "revision not found",
+			images:    []string{"app:v1", "app:v2"},
+			attrs:     map[string]string{"to_revision": "5"},
+			wantImage: "app:v2",
+			wantErr:   true,
+		},
+		{
+			name:      "no previous revision",
+			images:    []string{"app:v1"},
+			attrs:     map[string]string{},
+			wantImage: "app:v1",
+			wantErr:   true,
+		},
+		{
+			name:      "dry run",
+			images:    []string{"app:v1", "app:v2"},
+			attrs:     map[string]string{"dry_run": "true"},
+			wantImage: "app:v2",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+			c := newRolloutHistory(t, tt.images...)
+			a := action.NewK8sRollbackAction(logger, c)
+
+			attrs := map[string]string{
+				"namespace": "default",
+				"name":      "test-deployment",
+			}
+			for k, v := range tt.attrs {
+				attrs[k] = v
+			}
+
+			err := a.Run(t.Context(), dispatcher.DispatchResult{
+				Attrs: attrs,
+			})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Run() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			deployment := &appsv1.Deployment{}
+			assert.NoError(t, c.Get(t.Context(), client.ObjectKey{
+				Name:      "test-deployment",
+				Namespace: "default",
+			}, deployment))
+			assert.Equal(t, tt.wantImage, deployment.Spec.Template.Spec.Containers[0].Image)
+			assert.NotContains(t, deployment.Spec.Template.Labels, "pod-template-hash")
+		})
+	}
+}
@@ -2,20 +2,25 @@ package action
 
 import (
 	"context"
+	"fmt"
 	"log/slog"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/Drumato/amgate/pkg/dispatcher"
 	"github.com/cockroachdb/errors"
 	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 type K8sRolloutAction struct {
-	logger    *slog.Logger
-	k8sClient client.Client
+	logger       *slog.Logger
+	k8sClient    client.Client
+	pollInterval time.Duration
 }
 
 func (a *K8sRolloutAction) Name() string {
@@ -23,101 +28,371 @@ func (a *K8sRolloutAction) Name() string {
 }
 
 func (a *K8sRolloutAction) Run(ctx context.Context, result dispatcher.DispatchResult) error {
-	cfg := a.collectConfig(result.Attrs)
+	cfg, err := a.collectConfig(result.Attrs)
+	if err != nil {
+		return err
+	}
 
 	// start rollout like `kubectl rollout restart`
 	// https://github.com/kubernetes/kubectl/blob/fd89c3d1570b30935474a96cf42677d89faa2482/pkg/polymorphichelpers/objectrestarter.go#L32
 
-	var patch client.Patch
-	var targetObject client.Object
+	targets, err := a.resolveTargets(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	if len(targets) > cfg.MaxTargets {
+		return errors.Newf("selector %q matches %d workloads, exceeding max_targets %d", cfg.Selector, len(targets), cfg.MaxTargets)
+	}
 
-	switch cfg.Kind {
-	case "Deployment":
-		deployment := appsv1.Deployment{}
-		if err := a.k8sClient.Get(ctx, types.NamespacedName{
-			Namespace: cfg.Namespace,
-			Name:      cfg.Name,
-		}, &deployment); err != nil {
-			return errors.WithStack(err)
+	var errs error
+	restarted := []client.Object{}
+	for _, target := range targets {
+		if err := a.restart(ctx, target, cfg.DryRun); err != nil {
+			errs = errors.CombineErrors(errs, err)
+			continue
 		}
-		patch = client.MergeFrom(deployment.DeepCopy())
+		restarted = append(restarted, target)
+	}
+
+	if cfg.Wait && !cfg.DryRun && len(restarted) > 0 {
+		errs = errors.CombineErrors(errs, a.waitForRollouts(ctx, restarted, cfg.Timeout))
+	}
 
-		if deployment.Spec.Template.Labels == nil {
-			deployment.Spec.Template.Labels = map[string]string{}
+	return errs
+}
+
+// resolveTargets returns the workloads named by the attrs or matching the selector.
+func (a *K8sRolloutAction) resolveTargets(ctx context.Context, cfg K8sRolloutConfig) ([]client.Object, error) {
+	if cfg.Selector == "" {
+		target, err := newWorkload(cfg.Kind)
+		if err != nil {
+			return nil, err
 		}
-		deployment.Spec.Template.Labels["amgate.drumato.com/rollout"] = "true"
-		deployment.Spec.Template.Labels["amgate.drumato.com/restart-at"] = time.Now().Format(time.RFC3339)
-		targetObject = &deployment
-	case "StatefulSet":
-		statefulSet := appsv1.StatefulSet{}
 		if err := a.k8sClient.Get(ctx, types.NamespacedName{
 			Namespace: cfg.Namespace,
 			Name:      cfg.Name,
-		}, &statefulSet); err != nil {
-			return errors.WithStack(err)
+		}, target); err != nil {
+			return nil, errors.WithStack(err)
 		}
-		patch = client.MergeFrom(statefulSet.DeepCopy())
+		return []client.Object{target}, nil
+	}
 
-		if statefulSet.Spec.Template.Labels == nil {
-			statefulSet.Spec.Template.Labels = map[string]string{}
-		}
-		statefulSet.Spec.Template.Labels["amgate.drumato.com/rollout"] = "true"
-		statefulSet.Spec.Template.Labels["amgate.drumato.com/restart-at"] = time.Now().Format(time.RFC3339)
+	selector, err := labels.Parse(cfg.Selector)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid selector")
+	}
+	opts := []client.ListOption{
+		client.MatchingLabelsSelector{Selector: selector},
+	}
+	if cfg.Namespace != "" {
+		opts = append(opts, client.InNamespace(cfg.Namespace))
+	}
 
-		targetObject = &statefulSet
-	case "DaemonSet":
-		daemonSet := appsv1.DaemonSet{}
-		if err := a.k8sClient.Get(ctx, types.NamespacedName{
-			Namespace: cfg.Namespace,
-			Name:      cfg.Name,
-		}, &daemonSet); err != nil {
-			return errors.WithStack(err)
-		}
-		patch = client.MergeFrom(daemonSet.DeepCopy())
+	kinds := []string{"Deployment", "StatefulSet", "DaemonSet"}
+	if cfg.Kind != "" {
+		kinds = []string{cfg.Kind}
+	}
 
-		if daemonSet.Spec.Template.Labels == nil {
-			daemonSet.Spec.Template.Labels = map[string]string{}
+	targets := []client.Object{}
+	for _, kind := range kinds {
+		switch kind {
+		case "Deployment":
+			list := appsv1.DeploymentList{}
+			if err := a.k8sClient.List(ctx, &list, opts...); err != nil {
+				return nil, errors.WithStack(err)
+			}
+			for i := range list.Items {
+				targets = append(targets, &list.Items[i])
+			}
+		case "StatefulSet":
+			list := appsv1.StatefulSetList{}
+			if err := a.k8sClient.List(ctx, &list, opts...); err != nil {
+				return nil, errors.WithStack(err)
+			}
+			for i := range list.Items {
+				targets = append(targets, &list.Items[i])
+			}
+		case "DaemonSet":
+			list := appsv1.DaemonSetList{}
+			if err := a.k8sClient.List(ctx, &list, opts...); err != nil {
+				return nil, errors.WithStack(err)
+			}
+			for i := range list.Items {
+				targets = append(targets, &list.Items[i])
+			}
+		default:
+			return nil, errors.Newf("unsupported kind %q", kind)
 		}
-		daemonSet.Spec.Template.Labels["amgate.drumato.com/rollout"] = "true"
-		daemonSet.Spec.Template.Labels["amgate.drumato.com/restart-at"] = time.Now().Format(time.RFC3339)
+	}
+	if len(targets) == 0 {
+		return nil, errors.Newf("no workload matches selector %q", cfg.Selector)
+	}
+
+	return targets, nil
+}
+
+// restart patches the pod template of the workload to trigger a rollout.
+func (a *K8sRolloutAction) restart(ctx context.Context, target client.Object, dryRun bool) error {
+	patch := client.MergeFrom(target.DeepCopyObject().(client.Object))
 
-		targetObject = &daemonSet
+	var template *corev1.PodTemplateSpec
+	switch t := target.(type) {
+	case *appsv1.Deployment:
+		template = &t.Spec.Template
+	case *appsv1.StatefulSet:
+		template = &t.Spec.Template
+	case *appsv1.DaemonSet:
+		template = &t.Spec.Template
 	}
 
-	if !cfg.DryRun {
-		if err := a.k8sClient.Patch(ctx, targetObject, patch); err != nil {
+	if template.Labels == nil {
+		template.Labels = map[string]string{}
+	}
+	template.Labels["amgate.drumato.com/rollout"] = "true"
+	template.Labels["amgate.drumato.com/restart-at"] = time.Now().Format(time.RFC3339)
+
+	if !dryRun {
+		if err := a.k8sClient.Patch(ctx, target, patch); err != nil {
 			return errors.WithStack(err)
 		}
 	} else {
 		// dry-run
-		a.logger.Info("dry-run", slog.String("kind", cfg.Kind), slog.String("namespace", cfg.Namespace), slog.String("name", cfg.Name))
+		a.logger.Info("dry-run", slog.String("kind", kindOf(target)), slog.String("namespace", target.GetNamespace()), slog.String("name", target.GetName()))
 	}
 
 	return nil
 }
 
+func newWorkload(kind string) (client.Object, error) {
+	switch kind {
+	case "Deployment":
+		return &appsv1.Deployment{}, nil
+	case "StatefulSet":
+		return &appsv1.StatefulSet{}, nil
+	case "DaemonSet":
+		return &appsv1.DaemonSet{}, nil
+	default:
+		return nil, errors.Newf("unsupported kind %q", kind)
+	}
+}
+
+func kindOf(obj client.Object) string {
+	switch obj.(type) {
+	case *appsv1.Deployment:
+		return "Deployment"
+	case *appsv1.StatefulSet:
+		return "StatefulSet"
+	case *appsv1.DaemonSet:
+		return "DaemonSet"
+	default:
+		return ""
+	}
+}
+
 type K8sRolloutConfig struct {
 	DryRun bool
 
 	Kind      string
 	Namespace string
 	Name      string
+
+	// Selector is the label selector of the workloads to restart instead of Name.
+	// the workloads are listed across all namespaces if Namespace is empty,
+	// and of all the supported kinds if Kind is empty.
+	Selector string
+	// MaxTargets aborts the action if the selector matches more workloads than it.
+	MaxTargets int
+
+	// Wait waits until the rollout completes.
+	Wait bool
+	// Timeout is the timeout of waiting for the rollout.
+	// 0 waits until server.queue.actionTimeout.
+	Timeout time.Duration
 }
 
-func (a *K8sRolloutAction) collectConfig(attrs map[string]string) K8sRolloutConfig {
+func (a *K8sRolloutAction) collectConfig(attrs map[string]string) (K8sRolloutConfig, error) {
 	cfg := K8sRolloutConfig{}
 	cfg.Kind = attrs["kind"]
 	cfg.Namespace = attrs["namespace"]
 	cfg.Name = attrs["name"]
+	cfg.Selector = attrs["selector"]
+	if cfg.Name != "" && cfg.Selector != "" {
+		return K8sRolloutConfig{}, errors.New("name and selector are exclusive")
+	}
+
+	cfg.MaxTargets = 10
+	maxTargetsCfg, ok := attrs["max_targets"]
+	if ok {
+		maxTargets, err := strconv.Atoi(maxTargetsCfg)
+		if err != nil || maxTargets < 1 {
+			return K8sRolloutConfig{}, errors.Newf("invalid max_targets %q", maxTargetsCfg)
+		}
+		cfg.MaxTargets = maxTargets
+	}
+
 	dryRunCfg, ok := attrs["dry_run"]
 	if ok {
 		dryRun, err := strconv.ParseBool(dryRunCfg)
 		if err != nil {
-			cfg.DryRun = dryRun
+			return K8sRolloutConfig{}, errors.Wrap(err, "invalid dry_run")
+		}
+		cfg.DryRun = dryRun
+	}
+
+	waitCfg, ok := attrs["wait"]
+	if ok {
+		wait, err := strconv.ParseBool(waitCfg)
+		if err != nil {
+			return K8sRolloutConfig{}, errors.Wrap(err, "invalid wait")
+		}
+		cfg.Wait = wait
+	}
+
+	// waits until server.queue.actionTimeout by default.
+	timeoutCfg, ok := attrs["timeout"]
+	if ok {
+		timeout, err := time.ParseDuration(timeoutCfg)
+		if err != nil {
+			return K8sRolloutConfig{}, errors.Wrap(err, "invalid timeout")
 		}
+		cfg.Timeout = timeout
+	}
+
+	return cfg, nil
+}
+
+// waitForRollout polls the status of the workload until the rollout completes like `kubectl rollout status`.
+// https://github.com/kubernetes/kubectl/blob/fd89c3d1570b30935474a96cf42677d89faa2482/pkg/polymorphichelpers/rollout_status.go
+// waitForRollouts polls the workloads together until all of them complete,
+// so that timeout bounds the whole wait instead of each workload.
+// the workloads that fail or do not complete in time are reported in the error.
+func (a *K8sRolloutAction) waitForRollouts(ctx context.Context, targets []client.Object, timeout time.Duration) error {
+	var errs error
+	pending := targets
+	err := pollUntilDone(ctx, a.pollInterval, timeout, "rollout", func(ctx context.Context) (bool, error) {
+		rest := []client.Object{}
+		for _, target := range pending {
+			kind, key := kindOf(target), client.ObjectKeyFromObject(target)
+			logger := a.logger.With(slog.String("kind", kind), slog.String("namespace", key.Namespace), slog.String("name", key.Name))
+
+			done, err := a.rolledOut(ctx, kind, key)
+			switch {
+			case err != nil:
+				logger.ErrorContext(ctx, "rollout did not complete", slog.String("error", err.Error()))
+				errs = errors.CombineErrors(errs, errors.Wrapf(err, "rollout of %s %s", kind, key))
+			case done:
+				logger.InfoContext(ctx, "rollout completed")
+			default:
+				rest = append(rest, target)
+			}
+		}
+		pending = rest
+		return len(pending) == 0, nil
+	})
+	if err != nil {
+		a.logger.ErrorContext(ctx, "rollout did not complete", slog.String("pending", describeWorkloads(pending)), slog.String("error", err.Error()))
+		errs = errors.CombineErrors(errs, errors.Wrapf(err, "workloads %s", describeWorkloads(pending)))
+	}
+
+	return errs
+}
+
+// rolledOut reports whether the rollout of the workload completed.
+func (a *K8sRolloutAction) rolledOut(ctx context.Context, kind string, key types.NamespacedName) (bool, error) {
+	switch kind {
+	case "Deployment":
+		deployment := appsv1.Deployment{}
+		if err := a.k8sClient.Get(ctx, key, &deployment); err != nil {
+			return false, errors.WithStack(err)
+		}
+		return deploymentRolledOut(&deployment)
+	case "StatefulSet":
+		statefulSet := appsv1.StatefulSet{}
+		if err := a.k8sClient.Get(ctx, key, &statefulSet); err != nil {
+			return false, errors.WithStack(err)
+		}
+		return statefulSetRolledOut(&statefulSet)
+	case "DaemonSet":
+		daemonSet := appsv1.DaemonSet{}
+		if err := a.k8sClient.Get(ctx, key, &daemonSet); err != nil {
+			return false, errors.WithStack(err)
+		}
+		return daemonSetRolledOut(&daemonSet)
+	default:
+		return false, errors.Newf("unsupported kind %q", kind)
+	}
+}
+
+// describeWorkloads returns the workloads like "Deployment default/api, StatefulSet default/db".
+func describeWorkloads(targets []client.Object) string {
+	names := make([]string, 0, len(targets))
+	for _, target := range targets {
+		names = append(names, fmt.Sprintf("%s %s", kindOf(target), client.ObjectKeyFromObject(target)))
+	}
+	return strings.Join(names, ", ")
+}
+
+func deploymentRolledOut(deployment *appsv1.Deployment) (bool, error) {
+	if deployment.Generation > deployment.Status.ObservedGeneration {
+		return false, nil
+	}
+
+	for _, cond := range deployment.Status.Conditions {
+		if cond.Type == appsv1.DeploymentProgressing && cond.Reason == "ProgressDeadlineExceeded" {
+			return false, errors.Newf("deployment %s exceeded its progress deadline", deployment.Name)
+		}
+	}
+
+	if deployment.Spec.Replicas != nil && deployment.Status.UpdatedReplicas < *deployment.Spec.Replicas {
+		return false, nil
+	}
+	if deployment.Status.Replicas > deployment.Status.UpdatedReplicas {
+		// old replicas are pending termination.
+		return false, nil
+	}
+	if deployment.Status.AvailableReplicas < deployment.Status.UpdatedReplicas {
+		return false, nil
+	}
+
+	return true, nil
+}
+
+func statefulSetRolledOut(statefulSet *appsv1.StatefulSet) (bool, error) {
+	if statefulSet.Spec.UpdateStrategy.Type == appsv1.OnDeleteStatefulSetStrategyType {
+		return false, errors.New("rollout status is only available for RollingUpdate strategy type")
+	}
+	if statefulSet.Status.ObservedGeneration == 0 || statefulSet.Generation > statefulSet.Status.ObservedGeneration {
+		return false, nil
+	}
+
+	if statefulSet.Spec.Replicas != nil && statefulSet.Status.ReadyReplicas < *statefulSet.Spec.Replicas {
+		return false, nil
+	}
+
+	rollingUpdate := statefulSet.Spec.UpdateStrategy.RollingUpdate
+	if rollingUpdate != nil && rollingUpdate.Partition != nil && statefulSet.Spec.Replicas != nil {
+		return statefulSet.Status.UpdatedReplicas >= *statefulSet.Spec.Replicas-*rollingUpdate.Partition, nil
+	}
+
+	return statefulSet.Status.UpdateRevision == statefulSet.Status.CurrentRevision, nil
+}
+
+func daemonSetRolledOut(daemonSet *appsv1.DaemonSet) (bool, error) {
+	if daemonSet.Spec.UpdateStrategy.Type == appsv1.OnDeleteDaemonSetStrategyType {
+		return false, errors.New("rollout status is only available for RollingUpdate strategy type")
+	}
+	if daemonSet.Generation > daemonSet.Status.ObservedGeneration {
+		return false, nil
+	}
+
+	if daemonSet.Status.UpdatedNumberScheduled < daemonSet.Status.DesiredNumberScheduled {
+		return false, nil
+	}
+	if daemonSet.Status.NumberAvailable < daemonSet.Status.DesiredNumberScheduled {
+		return false, nil
 	}
 
-	return cfg
+	return true, nil
 }
 
 func NewK8sRolloutAction(
@@ -126,7 +401,8 @@ func NewK8sRolloutAction(
 ) Action {
 	actionLogger := logger.With(slog.String("action", "k8s-rollout"))
 	return &K8sRolloutAction{
-		logger:    actionLogger,
-		k8sClient: k8sClient,
+		logger:       actionLogger,
+		k8sClient:    k8sClient,
+		pollInterval: 2 * time.Second,
 	}
 }
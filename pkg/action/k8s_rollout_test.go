@@ -1,15 +1,19 @@
 package action_test
 
 import (
+	"context"
 	"io"
 	"log/slog"
 	"testing"
+	"time"
 
 	"github.com/Drumato/amgate/pkg/action"
 	"github.com/Drumato/amgate/pkg/dispatcher"
 	"github.com/stretchr/testify/assert"
 	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 )
@@ -115,6 +119,276 @@ func TestK8sRolloutAction_Run(t *testing.T) {
 				return nil
 			},
 		},
+		{
+			name: "deployment wait completed",
+			clientFn: func() client.Client {
+				c := fake.NewClientBuilder().Build()
+				err := c.Create(t.Context(), &appsv1.Deployment{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "test-deployment",
+						Namespace: "default",
+					},
+					Spec: appsv1.DeploymentSpec{
+						Replicas: ptr.To[int32](2),
+					},
+					Status: appsv1.DeploymentStatus{
+						Replicas:          2,
+						UpdatedReplicas:   2,
+						AvailableReplicas: 2,
+					},
+				})
+				assert.NoError(t, err)
+				return c
+			},
+			attrs: map[string]string{
+				"kind":      "Deployment",
+				"name":      "test-deployment",
+				"namespace": "default",
+				"wait":      "true",
+				"timeout":   "1s",
+			},
+			verifyFn: func(c client.Client) error { return nil },
+		},
+		{
+			name: "deployment wait progress deadline exceeded",
+			clientFn: func() client.Client {
+				c := fake.NewClientBuilder().Build()
+				err := c.Create(t.Context(), &appsv1.Deployment{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "test-deployment",
+						Namespace: "default",
+					},
+					Spec: appsv1.DeploymentSpec{
+						Replicas: ptr.To[int32](2),
+					},
+					Status: appsv1.DeploymentStatus{
+						Replicas:        3,
+						UpdatedReplicas: 1,
+						Conditions: []appsv1.DeploymentCondition{
+							{
+								Type:   appsv1.DeploymentProgressing,
+								Status: corev1.ConditionFalse,
+								Reason: "ProgressDeadlineExceeded",
+							},
+						},
+					},
+				})
+				assert.NoError(t, err)
+				return c
+			},
+			attrs: map[string]string{
+				"kind":      "Deployment",
+				"name":      "test-deployment",
+				"namespace": "default",
+				"wait":      "true",
+				"timeout":   "1m",
+			},
+			verifyFn: func(c client.Client) error { return nil },
+			wantErr:  true,
+		},
+		{
+			name: "statefulset wait timeout",
+			clientFn: func() client.Client {
+				c := fake.NewClientBuilder().Build()
+				err := c.Create(t.Context(), &appsv1.StatefulSet{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "test-statefulset",
+						Namespace: "default",
+					},
+					Spec: appsv1.StatefulSetSpec{
+						Replicas: ptr.To[int32](2),
+					},
+					Status: appsv1.StatefulSetStatus{
+						ObservedGeneration: 1,
+						ReadyReplicas:      1,
+					},
+				})
+				assert.NoError(t, err)
+				return c
+			},
+			attrs: map[string]string{
+				"kind":      "StatefulSet",
+				"name":      "test-statefulset",
+				"namespace": "default",
+				"wait":      "true",
+				"timeout":   "100ms",
+			},
+			verifyFn: func(c client.Client) error { return nil },
+			wantErr:  true,
+		},
+		{
+			name: "dry run",
+			clientFn: func() client.Client {
+				c := fake.NewClientBuilder().Build()
+				err := c.Create(t.Context(), &appsv1.Deployment{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "test-deployment",
+						Namespace: "default",
+					},
+				})
+				assert.NoError(t, err)
+				return c
+			},
+			attrs: map[string]string{
+				"kind":      "Deployment",
+				"name":      "test-deployment",
+				"namespace": "default",
+				"dry_run":   "true",
+			},
+			verifyFn: func(c client.Client) error {
+				deployment := &appsv1.Deployment{}
+				err := c.Get(t.Context(), client.ObjectKey{
+					Name:      "test-deployment",
+					Namespace: "default",
+				}, deployment)
+				if err != nil {
+					return err
+				}
+				assert.Empty(t, deployment.Spec.Template.Labels["amgate.drumato.com/rollout"])
+				return nil
+			},
+		},
+		{
+			name: "selector",
+			clientFn: func() client.Client {
+				c := fake.NewClientBuilder().Build()
+				for _, obj := range []client.Object{
+					&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "checkout", Namespace: "default", Labels: map[string]string{"app.kubernetes.io/part-of": "checkout"}}},
+					&appsv1.StatefulSet{ObjectMeta: metav1.ObjectMeta{Name: "checkout-db", Namespace: "db", Labels: map[string]string{"app.kubernetes.io/part-of": "checkout"}}},
+					&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "cart", Namespace: "default", Labels: map[string]string{"app.kubernetes.io/part-of": "cart"}}},
+				} {
+					assert.NoError(t, c.Create(t.Context(), obj))
+				}
+				return c
+			},
+			attrs: map[string]string{
+				"selector": "app.kubernetes.io/part-of=checkout",
+			},
+			verifyFn: func(c client.Client) error {
+				checkout := &appsv1.Deployment{}
+				if err := c.Get(t.Context(), client.ObjectKey{Name: "checkout", Namespace: "default"}, checkout); err != nil {
+					return err
+				}
+				assert.Equal(t, "true", checkout.Spec.Template.Labels["amgate.drumato.com/rollout"])
+
+				checkoutDB := &appsv1.StatefulSet{}
+				if err := c.Get(t.Context(), client.ObjectKey{Name: "checkout-db", Namespace: "db"}, checkoutDB); err != nil {
+					return err
+				}
+				assert.Equal(t, "true", checkoutDB.Spec.Template.Labels["amgate.drumato.com/rollout"])
+
+				cart := &appsv1.Deployment{}
+				if err := c.Get(t.Context(), client.ObjectKey{Name: "cart", Namespace: "default"}, cart); err != nil {
+					return err
+				}
+				assert.Empty(t, cart.Spec.Template.Labels["amgate.drumato.com/rollout"])
+				return nil
+			},
+		},
+		{
+			name: "selector with namespace and kind",
+			clientFn: func() client.Client {
+				c := fake.NewClientBuilder().Build()
+				for _, obj := range []client.Object{
+					&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "checkout", Namespace: "default", Labels: map[string]string{"app.kubernetes.io/part-of": "checkout"}}},
+					&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "checkout", Namespace: "staging", Labels: map[string]string{"app.kubernetes.io/part-of": "checkout"}}},
+					&appsv1.DaemonSet{ObjectMeta: metav1.ObjectMeta{Name: "checkout-agent", Namespace: "default", Labels: map[string]string{"app.kubernetes.io/part-of": "checkout"}}},
+				} {
+					assert.NoError(t, c.Create(t.Context(), obj))
+				}
+				return c
+			},
+			attrs: map[string]string{
+				"kind":      "Deployment",
+				"namespace": "default",
+				"selector":  "app.kubernetes.io/part-of=checkout",
+			},
+			verifyFn: func(c client.Client) error {
+				deployment := &appsv1.Deployment{}
+				if err := c.Get(t.Context(), client.ObjectKey{Name: "checkout", Namespace: "default"}, deployment); err != nil {
+					return err
+				}
+				assert.Equal(t, "true", deployment.Spec.Template.Labels["amgate.drumato.com/rollout"])
+
+				staging := &appsv1.Deployment{}
+				if err := c.Get(t.Context(), client.ObjectKey{Name: "checkout", Namespace: "staging"}, staging); err != nil {
+					return err
+				}
+				assert.Empty(t, staging.Spec.Template.Labels["amgate.drumato.com/rollout"])
+
+				daemonSet := &appsv1.DaemonSet{}
+				if err := c.Get(t.Context(), client.ObjectKey{Name: "checkout-agent", Namespace: "default"}, daemonSet); err != nil {
+					return err
+				}
+				assert.Empty(t, daemonSet.Spec.Template.Labels["amgate.drumato.com/rollout"])
+				return nil
+			},
+		},
+		{
+			name: "selector exceeds max targets",
+			clientFn: func() client.Client {
+				c := fake.NewClientBuilder().Build()
+				for _, name := range []string{"checkout-a", "checkout-b"} {
+					assert.NoError(t, c.Create(t.Context(), &appsv1.Deployment{
+						ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", Labels: map[string]string{"app.kubernetes.io/part-of": "checkout"}},
+					}))
+				}
+				return c
+			},
+			attrs: map[string]string{
+				"selector":    "app.kubernetes.io/part-of=checkout",
+				"max_targets": "1",
+			},
+			verifyFn: func(c client.Client) error {
+				deployments := &appsv1.DeploymentList{}
+				if err := c.List(t.Context(), deployments); err != nil {
+					return err
+				}
+				for _, deployment := range deployments.Items {
+					assert.Empty(t, deployment.Spec.Template.Labels["amgate.drumato.com/rollout"])
+				}
+				return nil
+			},
+			wantErr: true,
+		},
+		{
+			name: "selector matches nothing",
+			clientFn: func() client.Client {
+				return fake.NewClientBuilder().Build()
+			},
+			attrs: map[string]string{
+				"selector": "app.kubernetes.io/part-of=checkout",
+			},
+			verifyFn: func(c client.Client) error { return nil },
+			wantErr:  true,
+		},
+		{
+			name: "name and selector",
+			clientFn: func() client.Client {
+				return fake.NewClientBuilder().Build()
+			},
+			attrs: map[string]string{
+				"kind":     "Deployment",
+				"name":     "test-deployment",
+				"selector": "app.kubernetes.io/part-of=checkout",
+			},
+			verifyFn: func(c client.Client) error { return nil },
+			wantErr:  true,
+		},
+		{
+			name: "invalid wait",
+			clientFn: func() client.Client {
+				return fake.NewClientBuilder().Build()
+			},
+			attrs: map[string]string{
+				"kind":      "Deployment",
+				"name":      "test-deployment",
+				"namespace": "default",
+				"wait":      "maybe",
+			},
+			verifyFn: func(c client.Client) error { return nil },
+			wantErr:  true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -136,3 +410,73 @@ func TestK8sRolloutAction_Run(t *testing.T) {
 		})
 	}
 }
+
+func TestK8sRolloutAction_Run_WaitTimeout(t *testing.T) {
+	tests := []struct {
+		name    string
+		timeout string
+		wantErr string
+	}{
+		{
+			name:    "timeout attr",
+			timeout: "100ms",
+			wantErr: "did not complete within 100ms",
+		},
+		{
+			name:    "action timeout",
+			wantErr: "did not complete before the action timeout",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := fake.NewClientBuilder().WithObjects(&appsv1.StatefulSet{
+				ObjectMeta: metav1.ObjectMeta{Name: "test-statefulset", Namespace: "default"},
+				Spec:       appsv1.StatefulSetSpec{Replicas: ptr.To[int32](2)},
+			}).Build()
+			logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+			a := action.NewK8sRolloutAction(logger, c)
+
+			attrs := map[string]string{
+				"kind":      "StatefulSet",
+				"name":      "test-statefulset",
+				"namespace": "default",
+				"wait":      "true",
+			}
+			if tt.timeout != "" {
+				attrs["timeout"] = tt.timeout
+			}
+
+			// the deadline of the context is server.queue.actionTimeout.
+			ctx, cancel := context.WithTimeout(t.Context(), 500*time.Millisecond)
+			defer cancel()
+			err := a.Run(ctx, dispatcher.DispatchResult{Attrs: attrs})
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestK8sRolloutAction_Run_WaitSelector(t *testing.T) {
+	c := fake.NewClientBuilder().Build()
+	for _, name := range []string{"checkout-0", "checkout-1", "checkout-2", "checkout-3"} {
+		assert.NoError(t, c.Create(t.Context(), &appsv1.StatefulSet{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", Labels: map[string]string{"app.kubernetes.io/part-of": "checkout"}},
+			Spec:       appsv1.StatefulSetSpec{Replicas: ptr.To[int32](2)},
+		}))
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	a := action.NewK8sRolloutAction(logger, c)
+
+	start := time.Now()
+	err := a.Run(t.Context(), dispatcher.DispatchResult{Attrs: map[string]string{
+		"selector": "app.kubernetes.io/part-of=checkout",
+		"wait":     "true",
+		"timeout":  "300ms",
+	}})
+	assert.ErrorContains(t, err, "did not complete within 300ms")
+	for _, name := range []string{"checkout-0", "checkout-1", "checkout-2", "checkout-3"} {
+		assert.ErrorContains(t, err, "StatefulSet default/"+name)
+	}
+	// the timeout bounds the whole wait, not each workload.
+	assert.Less(t, time.Since(start), 900*time.Millisecond)
+}
@@ -0,0 +1,264 @@
+package action
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+
+	"github.com/Drumato/amgate/pkg/dispatcher"
+	"github.com/cockroachdb/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// originalReplicasAnnotation remembers the replicas before the action scales the workload.
+const originalReplicasAnnotation = "amgate.drumato.com/scale-original-replicas"
+
+type K8sScaleAction struct {
+	logger    *slog.Logger
+	k8sClient client.Client
+}
+
+func (a *K8sScaleAction) Name() string {
+	return "k8s-scale"
+}
+
+func (a *K8sScaleAction) Run(ctx context.Context, result dispatcher.DispatchResult) error {
+	cfg, err := a.collectConfig(result.Attrs)
+	if err != nil {
+		return err
+	}
+	if result.Alert.Alert.Status == "resolved" && !cfg.RestoreOnResolved {
+		// never scale on the resolved notification.
+		return nil
+	}
+
+	target, err := newScaleTarget(cfg.Kind)
+	if err != nil {
+		return err
+	}
+	if err := a.k8sClient.Get(ctx, types.NamespacedName{
+		Namespace: cfg.Namespace,
+		Name:      cfg.Name,
+	}, target); err != nil {
+		return errors.WithStack(err)
+	}
+
+	logger := a.logger.With(slog.String("kind", cfg.Kind), slog.String("namespace", cfg.Namespace), slog.String("name", cfg.Name))
+	patch := client.MergeFrom(target.DeepCopyObject().(client.Object))
+	current := replicasOf(target)
+	annotations := target.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+
+	var desired int32
+	if result.Alert.Alert.Status == "resolved" && cfg.RestoreOnResolved {
+		originalCfg, ok := annotations[originalReplicasAnnotation]
+		if !ok {
+			logger.InfoContext(ctx, "no original replicas to restore")
+			return nil
+		}
+		original, err := strconv.ParseInt(originalCfg, 10, 32)
+		if err != nil {
+			return errors.Wrapf(err, "invalid %s annotation", originalReplicasAnnotation)
+		}
+
+		desired = int32(original)
+		delete(annotations, originalReplicasAnnotation)
+	} else {
+		desired = cfg.desiredReplicas(current)
+		if _, ok := annotations[originalReplicasAnnotation]; !ok && cfg.RestoreOnResolved {
+			// keep the value before the first scaling even if the alert fires repeatedly.
+			annotations[originalReplicasAnnotation] = strconv.Itoa(int(current))
+		}
+	}
+	if hpa, ok := target.(*autoscalingv2.HorizontalPodAutoscaler); ok && desired > hpa.Spec.MaxReplicas {
+		desired = hpa.Spec.MaxReplicas
+	}
+
+	SetOutput(ctx, "previous_replicas", strconv.Itoa(int(current)))
+	SetOutput(ctx, "replicas", strconv.Itoa(int(desired)))
+
+	if cfg.DryRun {
+		// dry-run
+		logger.Info("dry-run", slog.Int("fromReplicas", int(current)), slog.Int("toReplicas", int(desired)))
+		return nil
+	}
+
+	target.SetAnnotations(annotations)
+	setReplicas(target, desired)
+	if err := a.k8sClient.Patch(ctx, target, patch); err != nil {
+		return errors.WithStack(err)
+	}
+
+	logger.InfoContext(ctx, "scaled", slog.Int("fromReplicas", int(current)), slog.Int("toReplicas", int(desired)))
+	return nil
+}
+
+func newScaleTarget(kind string) (client.Object, error) {
+	switch kind {
+	case "Deployment":
+		return &appsv1.Deployment{}, nil
+	case "StatefulSet":
+		return &appsv1.StatefulSet{}, nil
+	case "HorizontalPodAutoscaler":
+		return &autoscalingv2.HorizontalPodAutoscaler{}, nil
+	default:
+		return nil, errors.Newf("unsupported kind %q", kind)
+	}
+}
+
+// replicasOf returns the replicas of the workload, or the minReplicas of the HPA.
+// the unset value is defaulted to 1 like the API server.
+func replicasOf(obj client.Object) int32 {
+	var replicas *int32
+	switch t := obj.(type) {
+	case *appsv1.Deployment:
+		replicas = t.Spec.Replicas
+	case *appsv1.StatefulSet:
+		replicas = t.Spec.Replicas
+	case *autoscalingv2.HorizontalPodAutoscaler:
+		replicas = t.Spec.MinReplicas
+	}
+	return ptr.Deref(replicas, 1)
+}
+
+func setReplicas(obj client.Object, replicas int32) {
+	switch t := obj.(type) {
+	case *appsv1.Deployment:
+		t.Spec.Replicas = ptr.To(replicas)
+	case *appsv1.StatefulSet:
+		t.Spec.Replicas = ptr.To(replicas)
+	case *autoscalingv2.HorizontalPodAutoscaler:
+		t.Spec.MinReplicas = ptr.To(replicas)
+	}
+}
+
+type K8sScaleConfig struct {
+	DryRun bool
+
+	// Kind is one of Deployment, StatefulSet and HorizontalPodAutoscaler.
+	// the minReplicas is scaled for HorizontalPodAutoscaler.
+	Kind      string
+	Namespace string
+	Name      string
+
+	// Operation is one of set, increment and decrement.
+	Operation string
+	// Replicas is the replicas to set, or the amount to increment/decrement.
+	Replicas int32
+	// Min and Max bound the scaled replicas.
+	Min int32
+	Max *int32
+
+	// RestoreOnResolved restores the original replicas when the alert is resolved.
+	RestoreOnResolved bool
+}
+
+func (c K8sScaleConfig) desiredReplicas(current int32) int32 {
+	desired := c.Replicas
+	switch c.Operation {
+	case "increment":
+		desired = current + c.Replicas
+	case "decrement":
+		desired = current - c.Replicas
+	}
+
+	desired = max(desired, c.Min)
+	if c.Max != nil {
+		desired = min(desired, *c.Max)
+	}
+	return desired
+}
+
+func (a *K8sScaleAction) collectConfig(attrs map[string]string) (K8sScaleConfig, error) {
+	cfg := K8sScaleConfig{}
+	cfg.Kind = attrs["kind"]
+	cfg.Namespace = attrs["namespace"]
+	cfg.Name = attrs["name"]
+	if cfg.Name == "" {
+		return K8sScaleConfig{}, errors.New("name is required")
+	}
+
+	dryRunCfg, ok := attrs["dry_run"]
+	if ok {
+		dryRun, err := strconv.ParseBool(dryRunCfg)
+		if err != nil {
+			return K8sScaleConfig{}, errors.Wrap(err, "invalid dry_run")
+		}
+		cfg.DryRun = dryRun
+	}
+
+	cfg.Operation = "set"
+	if operation, ok := attrs["operation"]; ok {
+		cfg.Operation = operation
+	}
+
+	replicasCfg, ok := attrs["replicas"]
+	switch {
+	case ok:
+		replicas, err := strconv.ParseInt(replicasCfg, 10, 32)
+		if err != nil || replicas < 0 {
+			return K8sScaleConfig{}, errors.Newf("invalid replicas %q", replicasCfg)
+		}
+		cfg.Replicas = int32(replicas)
+	case cfg.Operation == "set":
+		return K8sScaleConfig{}, errors.New("replicas is required for set operation")
+	default:
+		cfg.Replicas = 1
+	}
+
+	switch cfg.Operation {
+	case "set", "increment", "decrement":
+	default:
+		return K8sScaleConfig{}, errors.Newf("unsupported operation %q", cfg.Operation)
+	}
+
+	minCfg, ok := attrs["min"]
+	if ok {
+		minReplicas, err := strconv.ParseInt(minCfg, 10, 32)
+		if err != nil || minReplicas < 0 {
+			return K8sScaleConfig{}, errors.Newf("invalid min %q", minCfg)
+		}
+		cfg.Min = int32(minReplicas)
+	}
+	if cfg.Kind == "HorizontalPodAutoscaler" {
+		// minReplicas of HPA must be greater than 0.
+		cfg.Min = max(cfg.Min, 1)
+	}
+
+	maxCfg, ok := attrs["max"]
+	if ok {
+		maxReplicas, err := strconv.ParseInt(maxCfg, 10, 32)
+		if err != nil || int32(maxReplicas) < cfg.Min {
+			return K8sScaleConfig{}, errors.Newf("invalid max %q", maxCfg)
+		}
+		cfg.Max = ptr.To(int32(maxReplicas))
+	}
+
+	restoreCfg, ok := attrs["restore_on_resolved"]
+	if ok {
+		restore, err := strconv.ParseBool(restoreCfg)
+		if err != nil {
+			return K8sScaleConfig{}, errors.Wrap(err, "invalid restore_on_resolved")
+		}
+		cfg.RestoreOnResolved = restore
+	}
+
+	return cfg, nil
+}
+
+func NewK8sScaleAction(
+	logger *slog.Logger,
+	k8sClient client.Client,
+) Action {
+	actionLogger := logger.With(slog.String("action", "k8s-scale"))
+	return &K8sScaleAction{
+		logger:    actionLogger,
+		k8sClient: k8sClient,
+	}
+}
@@ -0,0 +1,193 @@
+package action_test
+
+import (
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/Drumato/amgate/pkg/action"
+	"github.com/Drumato/amgate/pkg/alertmanager"
+	"github.com/Drumato/amgate/pkg/dispatcher"
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestK8sScaleAction_Run(t *testing.T) {
+	tests := []struct {
+		name           string
+		object         client.Object
+		status         string
+		attrs          map[string]string
+		wantReplicas   int32
+		wantAnnotation string
+		wantErr        bool
+	}{
+		{
+			name:         "set",
+			object:       newScaleDeployment(2, nil),
+			attrs:        map[string]string{"replicas": "5"},
+			wantReplicas: 5,
+		},
+		{
+			name:         "increment",
+			object:       newScaleDeployment(2, nil),
+			attrs:        map[string]string{"operation": "increment", "replicas": "3"},
+			wantReplicas: 5,
+		},
+		{
+			name:         "increment bounded by max",
+			object:       newScaleDeployment(4, nil),
+			attrs:        map[string]string{"operation": "increment", "replicas": "3", "max": "6"},
+			wantReplicas: 6,
+		},
+		{
+			name:         "decrement bounded by min",
+			object:       newScaleDeployment(2, nil),
+			attrs:        map[string]string{"operation": "decrement", "replicas": "3", "min": "1"},
+			wantReplicas: 1,
+		},
+		{
+			name:           "remember original replicas",
+			object:         newScaleDeployment(2, nil),
+			attrs:          map[string]string{"operation": "increment", "restore_on_resolved": "true"},
+			wantReplicas:   3,
+			wantAnnotation: "2",
+		},
+		{
+			name:           "keep original replicas on repeated firing",
+			object:         newScaleDeployment(3, map[string]string{"amgate.drumato.com/scale-original-replicas": "2"}),
+			attrs:          map[string]string{"operation": "increment", "restore_on_resolved": "true"},
+			wantReplicas:   4,
+			wantAnnotation: "2",
+		},
+		{
+			name:         "restore on resolved",
+			object:       newScaleDeployment(4, map[string]string{"amgate.drumato.com/scale-original-replicas": "2"}),
+			status:       "resolved",
+			attrs:        map[string]string{"operation": "increment", "restore_on_resolved": "true"},
+			wantReplicas: 2,
+		},
+		{
+			name:         "no scaling on resolved",
+			object:       newScaleDeployment(4, nil),
+			status:       "resolved",
+			attrs:        map[string]string{"operation": "increment"},
+			wantReplicas: 4,
+		},
+		{
+			name:         "nothing to restore",
+			object:       newScaleDeployment(4, nil),
+			status:       "resolved",
+			attrs:        map[string]string{"operation": "increment", "restore_on_resolved": "true"},
+			wantReplicas: 4,
+		},
+		{
+			name:         "dry run",
+			object:       newScaleDeployment(2, nil),
+			attrs:        map[string]string{"replicas": "5", "dry_run": "true"},
+			wantReplicas: 2,
+		},
+		{
+			name:         "set requires replicas",
+			object:       newScaleDeployment(2, nil),
+			attrs:        map[string]string{},
+			wantReplicas: 2,
+			wantErr:      true,
+		},
+		{
+			name:         "unsupported operation",
+			object:       newScaleDeployment(2, nil),
+			attrs:        map[string]string{"operation": "multiply", "replicas": "2"},
+			wantReplicas: 2,
+			wantErr:      true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+			c := fake.NewClientBuilder().WithObjects(tt.object).Build()
+			a := action.NewK8sScaleAction(logger, c)
+
+			attrs := map[string]string{
+				"kind":      "Deployment",
+				"namespace": "default",
+				"name":      "test-deployment",
+			}
+			for k, v := range tt.attrs {
+				attrs[k] = v
+			}
+
+			err := a.Run(t.Context(), dispatcher.DispatchResult{
+				Alert: dispatcher.DispatchAlert{
+					Alert: alertmanager.Alert{Status: tt.status},
+				},
+				Attrs: attrs,
+			})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Run() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			deployment := &appsv1.Deployment{}
+			assert.NoError(t, c.Get(t.Context(), client.ObjectKey{
+				Name:      "test-deployment",
+				Namespace: "default",
+			}, deployment))
+			assert.Equal(t, tt.wantReplicas, *deployment.Spec.Replicas)
+			assert.Equal(t, tt.wantAnnotation, deployment.Annotations["amgate.drumato.com/scale-original-replicas"])
+		})
+	}
+}
+
+func TestK8sScaleAction_Run_HPA(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	c := fake.NewClientBuilder().WithObjects(&autoscalingv2.HorizontalPodAutoscaler{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-hpa",
+			Namespace: "default",
+		},
+		Spec: autoscalingv2.HorizontalPodAutoscalerSpec{
+			MinReplicas: ptr.To[int32](2),
+			MaxReplicas: 5,
+		},
+	}).Build()
+	a := action.NewK8sScaleAction(logger, c)
+
+	// minReplicas is bounded by maxReplicas of the HPA.
+	err := a.Run(t.Context(), dispatcher.DispatchResult{
+		Attrs: map[string]string{
+			"kind":      "HorizontalPodAutoscaler",
+			"namespace": "default",
+			"name":      "test-hpa",
+			"operation": "increment",
+			"replicas":  "10",
+		},
+	})
+	assert.NoError(t, err)
+
+	hpa := &autoscalingv2.HorizontalPodAutoscaler{}
+	assert.NoError(t, c.Get(t.Context(), client.ObjectKey{
+		Name:      "test-hpa",
+		Namespace: "default",
+	}, hpa))
+	assert.Equal(t, int32(5), *hpa.Spec.MinReplicas)
+}
+
+func newScaleDeployment(replicas int32, annotations map[string]string) *appsv1.Deployment {
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "test-deployment",
+			Namespace:   "default",
+			Annotations: annotations,
+		},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: ptr.To(replicas),
+		},
+	}
+}
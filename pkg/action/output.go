@@ -0,0 +1,42 @@
+package action
+
+import (
+	"context"
+	"maps"
+	"sync"
+)
+
+type outputsKey struct{}
+
+// outputs collects the outputs of an action run.
+type outputs struct {
+	mu     sync.Mutex
+	values map[string]string
+}
+
+// SetOutput records an output of the running action,
+// e.g. the name of the created Job.
+// the sequence action passes the outputs of a step to the attrs of the later steps.
+// it does nothing unless the action runs as a step.
+func SetOutput(ctx context.Context, key, value string) {
+	o, ok := ctx.Value(outputsKey{}).(*outputs)
+	if !ok {
+		return
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.values[key] = value
+}
+
+// withOutputs returns the context collecting the outputs set by SetOutput.
+func withOutputs(ctx context.Context) (context.Context, *outputs) {
+	o := &outputs{values: map[string]string{}}
+	return context.WithValue(ctx, outputsKey{}, o), o
+}
+
+func (o *outputs) snapshot() map[string]string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return maps.Clone(o.values)
+}
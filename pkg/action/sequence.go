@@ -0,0 +1,113 @@
+package action
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/Drumato/amgate/pkg/config"
+	"github.com/Drumato/amgate/pkg/dispatcher"
+	"github.com/cockroachdb/errors"
+)
+
+// SequenceAction runs the steps of the dispatched action in order.
+type SequenceAction struct {
+	logger *slog.Logger
+	// lookup returns the registered action by name.
+	lookup func(name string) (Action, bool)
+	// run runs the action of a step with the retry policy of the sequence.
+	run func(ctx context.Context, actor Action, result dispatcher.DispatchResult) error
+}
+
+func (a *SequenceAction) Name() string {
+	return config.SequenceActionName
+}
+
+// SequenceTemplateData is the data of the step attrs templates.
+// the fields of DispatchAlert are available as well as the attrs of the other actions.
+type SequenceTemplateData struct {
+	dispatcher.DispatchAlert
+	// Steps are the outputs of the finished steps keyed by the step name.
+	Steps map[string]map[string]string
+}
+
+func (a *SequenceAction) Run(ctx context.Context, result dispatcher.DispatchResult) error {
+	if len(result.Steps) == 0 {
+		return errors.New("steps are required")
+	}
+
+	data := SequenceTemplateData{
+		DispatchAlert: result.Alert,
+		Steps:         map[string]map[string]string{},
+	}
+	for _, step := range result.Steps {
+		logger := a.logger.With(slog.String("step", step.Name), slog.String("stepAction", step.Action))
+
+		err := a.runStep(ctx, step, result, &data)
+		if err == nil {
+			logger.InfoContext(ctx, "step succeeded")
+			continue
+		}
+		logger.ErrorContext(ctx, "step failed", slog.String("error", err.Error()), slog.String("onFailure", step.OnFailure))
+
+		switch step.OnFailure {
+		case config.OnFailureContinue:
+			continue
+		case config.OnFailureCompensate:
+			if compensateErr := a.runStep(ctx, *step.Compensate, result, &data); compensateErr != nil {
+				logger.ErrorContext(ctx, "compensating step failed", slog.String("error", compensateErr.Error()))
+				err = errors.CombineErrors(err, errors.Wrapf(compensateErr, "step %s", step.Compensate.Name))
+			} else {
+				logger.InfoContext(ctx, "compensating step succeeded")
+			}
+		}
+		return errors.Wrapf(err, "step %s", step.Name)
+	}
+
+	return nil
+}
+
+// runStep renders the attrs of the step and runs its action.
+// the step is retried by itself, so that the succeeded steps are not run again.
+// the outputs set by the action are recorded into data even if it fails.
+func (a *SequenceAction) runStep(ctx context.Context, step config.StepConfig, result dispatcher.DispatchResult, data *SequenceTemplateData) error {
+	actor, ok := a.lookup(step.Action)
+	if !ok {
+		return errors.Newf("action %s not found", step.Action)
+	}
+
+	attrs, err := dispatcher.RenderAttrs(step.Attrs, data, ListAttrs(actor)...)
+	if err != nil {
+		return err
+	}
+
+	stepCtx, outputs := withOutputs(ctx)
+	err = a.run(stepCtx, actor, dispatcher.DispatchResult{
+		ActionName: step.Action,
+		ActionID:   result.ActionID + "/" + step.Name,
+		Alert:      result.Alert,
+		Attrs:      attrs,
+		Retry:      result.Retry,
+	})
+	data.Steps[step.Name] = outputs.snapshot()
+	return err
+}
+
+// NewSequenceAction creates the sequence action.
+// run applies the retry policy to each step, and nil runs the steps without retry.
+func NewSequenceAction(
+	logger *slog.Logger,
+	lookup func(name string) (Action, bool),
+	run func(ctx context.Context, actor Action, result dispatcher.DispatchResult) error,
+) Action {
+	actionLogger := logger.With(slog.String("action", config.SequenceActionName))
+	if run == nil {
+		run = func(ctx context.Context, actor Action, result dispatcher.DispatchResult) error {
+			return actor.Run(ctx, result)
+		}
+	}
+	return &SequenceAction{
+		logger: actionLogger,
+		lookup: lookup,
+		run:    run,
+	}
+}
@@ -0,0 +1,153 @@
+package action_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/Drumato/amgate/pkg/action"
+	"github.com/Drumato/amgate/pkg/alertmanager"
+	"github.com/Drumato/amgate/pkg/config"
+	"github.com/Drumato/amgate/pkg/dispatcher"
+	"github.com/stretchr/testify/assert"
+)
+
+// stepAction records the attrs of its runs and sets its attrs as the outputs.
+type stepAction struct {
+	name  string
+	err   error
+	calls *[]string
+	attrs map[string]map[string]string
+}
+
+func (a *stepAction) Name() string {
+	return a.name
+}
+
+func (a *stepAction) Run(ctx context.Context, result dispatcher.DispatchResult) error {
+	*a.calls = append(*a.calls, a.name)
+	a.attrs[a.name] = result.Attrs
+	for k, v := range result.Attrs {
+		action.SetOutput(ctx, k, v)
+	}
+	return a.err
+}
+
+func TestSequenceAction_Run(t *testing.T) {
+	tests := []struct {
+		name      string
+		steps     []config.StepConfig
+		wantCalls []string
+		wantAttrs map[string]map[string]string
+		wantErr   bool
+	}{
+		{
+			name: "outputs",
+			steps: []config.StepConfig{
+				{Name: "scale", Action: "scale", Attrs: map[string]string{"replicas": "{{ .Alert.Labels.replicas }}"}},
+				{Name: "restart", Action: "restart", Attrs: map[string]string{"after": "{{ .Steps.scale.replicas }}"}},
+			},
+			wantCalls: []string{"scale", "restart"},
+			wantAttrs: map[string]map[string]string{
+				"scale":   {"replicas": "3"},
+				"restart": {"after": "3"},
+			},
+		},
+		{
+			name: "abort",
+			steps: []config.StepConfig{
+				{Name: "fail", Action: "fail", OnFailure: config.OnFailureAbort},
+				{Name: "restart", Action: "restart"},
+			},
+			wantCalls: []string{"fail"},
+			wantErr:   true,
+		},
+		{
+			name: "continue",
+			steps: []config.StepConfig{
+				{Name: "fail", Action: "fail", OnFailure: config.OnFailureContinue},
+				{Name: "restart", Action: "restart"},
+			},
+			wantCalls: []string{"fail", "restart"},
+		},
+		{
+			name: "compensate",
+			steps: []config.StepConfig{
+				{Name: "scale", Action: "scale", Attrs: map[string]string{"replicas": "5"}},
+				{
+					Name: "fail", Action: "fail", OnFailure: config.OnFailureCompensate,
+					Compensate: &config.StepConfig{Name: "scale-back", Action: "scale", Attrs: map[string]string{"replicas": "{{ .Steps.scale.replicas }}-1"}},
+				},
+				{Name: "restart", Action: "restart"},
+			},
+			wantCalls: []string{"scale", "fail", "scale"},
+			wantAttrs: map[string]map[string]string{
+				"scale": {"replicas": "5-1"},
+				"fail":  {},
+			},
+			wantErr: true,
+		},
+		{
+			name: "missing output",
+			steps: []config.StepConfig{
+				{Name: "restart", Action: "restart", Attrs: map[string]string{"after": "{{ .Steps.scale.replicas }}"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "unknown action",
+			steps: []config.StepConfig{
+				{Name: "unknown", Action: "unknown"},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := []string{}
+			attrs := map[string]map[string]string{}
+			actions := map[string]action.Action{}
+			for _, a := range []*stepAction{
+				{name: "scale"},
+				{name: "restart"},
+				{name: "fail", err: errors.New("failed")},
+			} {
+				a.calls, a.attrs = &calls, attrs
+				actions[a.name] = a
+			}
+
+			logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+			a := action.NewSequenceAction(logger, func(name string) (action.Action, bool) {
+				a, ok := actions[name]
+				return a, ok
+			}, nil)
+
+			for i := range tt.steps {
+				if tt.steps[i].Attrs == nil {
+					tt.steps[i].Attrs = map[string]string{}
+				}
+			}
+			err := a.Run(t.Context(), dispatcher.DispatchResult{
+				ActionName: "sequence",
+				Alert: dispatcher.DispatchAlert{
+					Alert: alertmanager.Alert{Labels: map[string]string{"replicas": "3"}},
+				},
+				Steps: tt.steps,
+			})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Run() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if tt.wantCalls != nil {
+				assert.Equal(t, tt.wantCalls, calls)
+			}
+			if tt.wantAttrs != nil {
+				assert.Equal(t, tt.wantAttrs, attrs)
+			}
+		})
+	}
+}
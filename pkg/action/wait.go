@@ -0,0 +1,30 @@
+package action
+
+import (
+	"context"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// pollUntilDone polls condition until it returns true.
+// timeout 0 waits until ctx is done, that is server.queue.actionTimeout for the dispatched actions.
+// the error tells which of timeout and ctx stopped the wait, as both interrupt the poll alike.
+func pollUntilDone(ctx context.Context, interval, timeout time.Duration, what string, condition wait.ConditionWithContextFunc) error {
+	pollCtx := ctx
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		pollCtx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	err := wait.PollUntilContextCancel(pollCtx, interval, true, condition)
+	if !wait.Interrupted(err) {
+		return errors.WithStack(err)
+	}
+	if ctx.Err() != nil {
+		return errors.Wrapf(ctx.Err(), "%s did not complete before the action timeout", what)
+	}
+	return errors.Newf("%s did not complete within %s", what, timeout)
+}
@@ -0,0 +1,32 @@
+package alertmanager
+
+import "time"
+
+// Silence is the silence of the Alertmanager v2 API.
+// https://github.com/prometheus/alertmanager/blob/main/api/v2/openapi.yaml
+type Silence struct {
+	ID        string           `json:"id,omitempty"`
+	Matchers  []SilenceMatcher `json:"matchers"`
+	StartsAt  time.Time        `json:"startsAt"`
+	EndsAt    time.Time        `json:"endsAt"`
+	CreatedBy string           `json:"createdBy"`
+	Comment   string           `json:"comment"`
+	Status    *SilenceStatus   `json:"status,omitempty"`
+}
+
+type SilenceMatcher struct {
+	Name    string `json:"name"`
+	Value   string `json:"value"`
+	IsRegex bool   `json:"isRegex"`
+	IsEqual bool   `json:"isEqual"`
+}
+
+type SilenceStatus struct {
+	// State is one of active, pending and expired.
+	State string `json:"state"`
+}
+
+// PostSilenceResponse is the response of POST /api/v2/silences.
+type PostSilenceResponse struct {
+	SilenceID string `json:"silenceID"`
+}
@@ -2,7 +2,14 @@ package config
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
 	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"time"
 
 	"github.com/cockroachdb/errors"
 	"github.com/samber/lo"
@@ -15,8 +22,63 @@ import (
 // Config represents the entire configuration of amgate.
 // that is stored in ConfigMap.
 type Config struct {
-	Server  ServerConfig   `yaml:"server"`
-	Actions []ActionConfig `yaml:"actions"`
+	Server   ServerConfig   `yaml:"server"`
+	Matching MatchingConfig `yaml:"matching"`
+	Actions  []ActionConfig `yaml:"actions"`
+	// Routes is the routing tree evaluated after Actions.
+	Routes []RouteConfig `yaml:"routes"`
+	// Exec is the configuration of the exec action.
+	Exec ExecConfig `yaml:"exec"`
+
+	// deprecations are the warnings about the deprecated forms found by ValidateAndDefault.
+	deprecations []string
+}
+
+// Deprecations returns the warnings about the deprecated forms in the configuration.
+// they are found by ValidateAndDefault and should be logged by the caller.
+func (c *Config) Deprecations() []string {
+	return c.deprecations
+}
+
+// RouteConfig represents a node of the routing tree like Alertmanager's route.
+// an alert matching a route is passed to its child routes in order,
+// and the evaluation stops at the first matching child unless the child has Continue.
+// the actions of a route are dispatched only when none of its child routes match.
+type RouteConfig struct {
+	// Matchers are evaluated in addition to the matchers of the parent routes.
+	// a route without matchers matches every alert.
+	Matchers []MatcherConfig `yaml:"matchers"`
+	// Actions are dispatched when the alert matches this route but none of its child routes.
+	Actions []ActionConfig `yaml:"actions,omitempty"`
+	// Routes are the child routes.
+	Routes []RouteConfig `yaml:"routes,omitempty"`
+	// Continue makes the evaluation continue to the next sibling route after this route matches.
+	Continue bool `yaml:"continue"`
+}
+
+// MatchingConfig represents the behavior of the matchers.
+type MatchingConfig struct {
+	// AnchoredRegex makes `=~` match the entire value like Alertmanager.
+	// it is false by default to keep the partial match of the existing configs.
+	// `!~` is always anchored.
+	AnchoredRegex bool `yaml:"anchoredRegex"`
+}
+
+// ExecConfig represents the configuration of the exec action.
+type ExecConfig struct {
+	// AllowedCommands are the absolute paths of the commands that the exec action may run.
+	// the exec action runs nothing if it is empty.
+	AllowedCommands []string `yaml:"allowedCommands"`
+}
+
+func (c *ExecConfig) ValidateAndDefault() error {
+	for i, command := range c.AllowedCommands {
+		if !filepath.IsAbs(command) {
+			return errors.Newf("allowedCommands[%d]: %q must be an absolute path", i, command)
+		}
+		c.AllowedCommands[i] = filepath.Clean(command)
+	}
+	return nil
 }
 
 // ServerConfig represents the configuration of the server.
@@ -25,13 +87,161 @@ type ServerConfig struct {
 	Host string `yaml:"host"`
 	// Port is the port of the server.
 	Port int `yaml:"port"`
+	// Queue is the configuration of the action execution queue.
+	Queue QueueConfig `yaml:"queue"`
+	// Auth is the authentication of the incoming webhooks.
+	Auth AuthConfig `yaml:"auth"`
+	// TLS enables HTTPS on the server.
+	TLS *TLSConfig `yaml:"tls,omitempty"`
+	// HealthPort serves /healthz and /metrics on a separate plain HTTP port.
+	// 0 disables the separate port.
+	HealthPort int `yaml:"healthPort"`
+}
+
+// TLSConfig represents the TLS configuration of the server.
+// the files are reloaded when they change, e.g. cert-manager rotates the mounted Secret.
+type TLSConfig struct {
+	// CertFile is the path of the PEM encoded certificate.
+	CertFile string `yaml:"certFile"`
+	// KeyFile is the path of the PEM encoded private key.
+	KeyFile string `yaml:"keyFile"`
+	// ClientCAFile is the path of the PEM encoded CA certificates
+	// that verify the client certificates(mutual TLS).
+	// the client certificate is not required if it's empty.
+	ClientCAFile string `yaml:"clientCAFile,omitempty"`
+}
+
+// AuthConfig represents the authentication of the incoming webhooks.
+// every configured method is required to pass.
+type AuthConfig struct {
+	// BearerToken requires `Authorization: Bearer <token>`
+	// like `http_config.authorization` of Alertmanager.
+	BearerToken *SecretSource `yaml:"bearerToken,omitempty"`
+	// BasicAuth requires the basic authentication
+	// like `http_config.basic_auth` of Alertmanager.
+	BasicAuth *BasicAuthConfig `yaml:"basicAuth,omitempty"`
+	// HMAC requires the HMAC signature of the request body.
+	HMAC *HMACConfig `yaml:"hmac,omitempty"`
+}
+
+// BasicAuthConfig represents the credentials of the basic authentication.
+type BasicAuthConfig struct {
+	Username string       `yaml:"username"`
+	Password SecretSource `yaml:"password"`
+}
+
+// HMACConfig represents the verification of the HMAC signature of the request body.
+// the signature is the hex digest, optionally prefixed by the algorithm like `sha256=`.
+type HMACConfig struct {
+	// Secret is the key of the HMAC.
+	Secret SecretSource `yaml:"secret"`
+	// Header is the request header that has the signature.
+	// the default is X-Amgate-Signature.
+	Header string `yaml:"header"`
+	// Algorithm is the hash algorithm, sha256 or sha512.
+	// the default is sha256.
+	Algorithm string `yaml:"algorithm"`
+}
+
+// QueueConfig represents the configuration of the queue
+// that the dispatched actions wait in until a worker runs them.
+type QueueConfig struct {
+	// Size is the maximum number of the dispatched actions waiting for execution.
+	Size int `yaml:"size"`
+	// Workers is the number of the workers that run the actions concurrently.
+	Workers int `yaml:"workers"`
+	// ActionTimeout is the timeout of each action execution.
+	ActionTimeout time.Duration `yaml:"actionTimeout"`
 }
 
 // ActionConfig represents the configuration of an action.
 type ActionConfig struct {
-	Matchers []MatcherConfig   `yaml:"matchers"`
-	Name     string            `yaml:"name"`
-	Attrs    map[string]string `yaml:"attrs,omitempty"`
+	Matchers []MatcherConfig `yaml:"matchers"`
+	// ID identifies the action in the configuration, and is used in the logs, the metrics and the cooldown.
+	// it must be unique across the actions and the routes.
+	// the default is Type if no other action has the same type.
+	// otherwise it is required, except for the deprecated form with Name
+	// that defaults to `<type>-<hash>` derived from the type, the matchers and the attrs.
+	ID string `yaml:"id,omitempty"`
+	// Type is the name of the registered action that runs, e.g. k8s-rollout.
+	Type string `yaml:"type,omitempty"`
+	// Name is the deprecated alias of Type.
+	Name  string            `yaml:"name,omitempty"`
+	Attrs map[string]string `yaml:"attrs,omitempty"`
+	// Retry is the retry policy applied when the action fails.
+	Retry RetryConfig `yaml:"retry,omitempty"`
+	// Cooldown suppresses the repeated executions for the same alert.
+	Cooldown CooldownConfig `yaml:"cooldown,omitempty"`
+	// Steps are the steps run in order by the sequence action.
+	Steps []StepConfig `yaml:"steps,omitempty"`
+}
+
+// SequenceActionName is the name of the action that runs Steps.
+const SequenceActionName = "sequence"
+
+// StepConfig represents a step of the sequence action.
+type StepConfig struct {
+	// Name identifies the step in the sequence.
+	// the later steps refer to its outputs by `{{ .Steps.<name>.<key> }}`.
+	Name string `yaml:"name"`
+	// Action is the name of the registered action that the step runs.
+	Action string            `yaml:"action"`
+	Attrs  map[string]string `yaml:"attrs,omitempty"`
+	// OnFailure is the policy when the step fails, one of abort, continue and compensate.
+	// abort stops the sequence and fails it, continue runs the next step,
+	// and compensate runs Compensate and then fails the sequence.
+	// the default is abort.
+	OnFailure string `yaml:"onFailure"`
+	// Compensate is the step run when this step fails with onFailure compensate.
+	Compensate *StepConfig `yaml:"compensate,omitempty"`
+}
+
+const (
+	OnFailureAbort      = "abort"
+	OnFailureContinue   = "continue"
+	OnFailureCompensate = "compensate"
+)
+
+// CooldownConfig represents the deduplication of an action per alert.
+// once the action is dispatched for an alert(identified by its fingerprint and status),
+// the same action is not dispatched again for the alert until the window expires.
+type CooldownConfig struct {
+	// Window is the duration while the repeated executions are suppressed.
+	// 0 disables the cooldown.
+	Window time.Duration `yaml:"window"`
+	// PerTarget also distinguishes the executions by the attrs of the action,
+	// so the same alert can trigger the action for different targets.
+	PerTarget bool `yaml:"perTarget"`
+}
+
+// RetryConfig represents the retry policy of an action.
+// a failed action is retried with exponential backoff
+// only when the error is classified as retryable.
+type RetryConfig struct {
+	// MaxAttempts is the maximum number of attempts including the first one.
+	// 1 disables retries.
+	MaxAttempts int `yaml:"maxAttempts"`
+	// InitialBackoff is the wait before the first retry.
+	// the wait doubles on each retry up to MaxBackoff.
+	InitialBackoff time.Duration `yaml:"initialBackoff"`
+	// MaxBackoff is the upper bound of the wait between retries.
+	MaxBackoff time.Duration `yaml:"maxBackoff"`
+	// Jitter is the ratio of the random wait added to each backoff.
+	// 0.2 makes a 10s backoff wait between 10s and 12s.
+	Jitter float64 `yaml:"jitter"`
+	// RetryableReasons is the list of the Kubernetes status reasons(e.g. Conflict) that are retried.
+	RetryableReasons []string `yaml:"retryableReasons,omitempty"`
+}
+
+// DefaultRetryableReasons is the list of the Kubernetes status reasons
+// that are regarded as transient by default.
+var DefaultRetryableReasons = []string{
+	"Conflict",
+	"TooManyRequests",
+	"ServerTimeout",
+	"Timeout",
+	"ServiceUnavailable",
+	"InternalError",
 }
 
 type MatcherConfig struct {
@@ -43,30 +253,45 @@ type MatcherConfig struct {
 	Annotations       LabelMatcherConfig `yaml:"annotations,omitempty"`
 	CommonLabels      LabelMatcherConfig `yaml:"commonLabels,omitempty"`
 	CommonAnnotations LabelMatcherConfig `yaml:"commonAnnotations,omitempty"`
+
+	// anchoredRegex is set from MatchingConfig.AnchoredRegex.
+	anchoredRegex bool
+	// regexp is compiled from RegexPattern by ValidateAndDefault.
+	regexp *regexp.Regexp
 }
 
 type LabelMatcherConfig struct {
 	Matchers []MatcherConfig `yaml:"matchers"`
 }
 
-func LoadFromConfigMap(
-	ctx context.Context,
-	k8sClient client.Client,
-) (Config, error) {
+// ConfigMapKey returns the namespaced name of the ConfigMap that stores the configuration.
+func ConfigMapKey() types.NamespacedName {
 	cmNamespace := os.Getenv("AMGATE_NAMESPACE")
 	cmNamespace = lo.If(cmNamespace != "", cmNamespace).Else("amgate-system")
 
 	cmName := os.Getenv("AMGATE_CONFIGMAP_NAME")
 	cmName = lo.If(cmName != "", cmName).Else("amgate-config")
 
-	cm := corev1.ConfigMap{}
-	if err := k8sClient.Get(ctx, types.NamespacedName{
+	return types.NamespacedName{
 		Namespace: cmNamespace,
 		Name:      cmName,
-	}, &cm); err != nil {
+	}
+}
+
+func LoadFromConfigMap(
+	ctx context.Context,
+	k8sClient client.Client,
+) (Config, error) {
+	cm := corev1.ConfigMap{}
+	if err := k8sClient.Get(ctx, ConfigMapKey(), &cm); err != nil {
 		return Config{}, errors.WithStack(err)
 	}
 
+	return FromConfigMap(&cm)
+}
+
+// FromConfigMap parses the configuration stored in the given ConfigMap.
+func FromConfigMap(cm *corev1.ConfigMap) (Config, error) {
 	cfg := Config{}
 	if v, ok := cm.Data["server"]; ok {
 		sc := ServerConfig{}
@@ -76,6 +301,14 @@ func LoadFromConfigMap(
 		cfg.Server = sc
 	}
 
+	if v, ok := cm.Data["matching"]; ok {
+		mc := MatchingConfig{}
+		if err := yaml.Unmarshal([]byte(v), &mc); err != nil {
+			return Config{}, errors.WithStack(err)
+		}
+		cfg.Matching = mc
+	}
+
 	if v, ok := cm.Data["actions"]; ok {
 		ac := []ActionConfig{}
 		if err := yaml.Unmarshal([]byte(v), &ac); err != nil {
@@ -84,6 +317,22 @@ func LoadFromConfigMap(
 		cfg.Actions = ac
 	}
 
+	if v, ok := cm.Data["routes"]; ok {
+		rc := []RouteConfig{}
+		if err := yaml.Unmarshal([]byte(v), &rc); err != nil {
+			return Config{}, errors.WithStack(err)
+		}
+		cfg.Routes = rc
+	}
+
+	if v, ok := cm.Data["exec"]; ok {
+		ec := ExecConfig{}
+		if err := yaml.Unmarshal([]byte(v), &ec); err != nil {
+			return Config{}, errors.WithStack(err)
+		}
+		cfg.Exec = ec
+	}
+
 	return cfg, nil
 }
 
@@ -94,20 +343,323 @@ func (c *Config) ValidateAndDefault() error {
 	if c.Server.Port == 0 {
 		c.Server.Port = 8080
 	}
+	if err := c.Server.Queue.ValidateAndDefault(); err != nil {
+		return err
+	}
+	if err := c.Server.Auth.ValidateAndDefault(); err != nil {
+		return errors.Wrap(err, "server.auth")
+	}
+	if c.Server.TLS != nil {
+		if c.Server.TLS.CertFile == "" || c.Server.TLS.KeyFile == "" {
+			return errors.New("server.tls: certFile and keyFile are required")
+		}
+	}
+	if c.Server.HealthPort != 0 && c.Server.HealthPort == c.Server.Port {
+		return errors.New("server.healthPort must differ from server.port")
+	}
+
+	if err := c.Exec.ValidateAndDefault(); err != nil {
+		return errors.Wrap(err, "exec")
+	}
+
+	for i := range c.Actions {
+		if err := c.Actions[i].validateAndDefault(c.Matching.AnchoredRegex); err != nil {
+			return errors.Wrapf(err, "actions[%d](%s)", i, c.Actions[i].Type)
+		}
+	}
 
+	for i := range c.Routes {
+		if err := c.Routes[i].validateAndDefault(c.Matching.AnchoredRegex); err != nil {
+			return errors.Wrapf(err, "routes[%d]", i)
+		}
+	}
+
+	deprecations, err := defaultActionIDs(c.actionConfigs())
+	if err != nil {
+		return err
+	}
+	c.deprecations = deprecations
+
+	return nil
+}
+
+// actionConfigs returns the flat actions and the actions of the routes in order.
+func (c *Config) actionConfigs() []*ActionConfig {
+	actions := []*ActionConfig{}
 	for i := range c.Actions {
-		if c.Actions[i].Name == "" {
-			return errors.New("action name is required")
+		actions = append(actions, &c.Actions[i])
+	}
+
+	var walk func(routes []RouteConfig)
+	walk = func(routes []RouteConfig) {
+		for i := range routes {
+			for j := range routes[i].Actions {
+				actions = append(actions, &routes[i].Actions[j])
+			}
+			walk(routes[i].Routes)
 		}
+	}
+	walk(c.Routes)
 
-		for j := range c.Actions[i].Matchers {
-			if err := c.Actions[i].Matchers[j].ValidateAndDefault(); err != nil {
-				return err
+	return actions
+}
+
+// defaultActionIDs sets the default IDs of the actions, and checks that the IDs are unique.
+// the default ID is the type, so the actions of a type used more than once require their IDs.
+// the older configurations with name keep loading, and their IDs are derived from the content instead.
+// the default IDs do not depend on the order of the actions, so reordering them keeps the metrics and the cooldowns.
+// it returns the warnings about the derived IDs.
+func defaultActionIDs(actions []*ActionConfig) ([]string, error) {
+	types := map[string]int{}
+	for _, a := range actions {
+		types[a.Type]++
+	}
+
+	deprecations := []string{}
+	ids := map[string]struct{}{}
+	for _, a := range actions {
+		if a.ID == "" {
+			switch {
+			case types[a.Type] == 1:
+				a.ID = a.Type
+			case a.Name != "":
+				id, err := a.contentID()
+				if err != nil {
+					return nil, err
+				}
+				a.ID = id
+				deprecations = append(deprecations, fmt.Sprintf(
+					"action %q is configured by the deprecated name without id, and its id is derived from its matchers and attrs. set type and id to keep the id when they change",
+					a.ID,
+				))
+			default:
+				return nil, errors.Newf("action type %q is used more than once, id is required for each of them", a.Type)
 			}
 		}
-		if c.Actions[i].Attrs == nil {
-			c.Actions[i].Attrs = map[string]string{}
+
+		if _, ok := ids[a.ID]; ok {
+			return nil, errors.Newf("duplicate action id %q", a.ID)
 		}
+		ids[a.ID] = struct{}{}
+	}
+
+	return deprecations, nil
+}
+
+// contentID returns `<type>-<hash>`, where the hash is derived from the type, the matchers and the attrs.
+func (a *ActionConfig) contentID() (string, error) {
+	content, err := yaml.Marshal(struct {
+		Type     string            `yaml:"type"`
+		Matchers []MatcherConfig   `yaml:"matchers"`
+		Attrs    map[string]string `yaml:"attrs"`
+	}{a.Type, a.Matchers, a.Attrs})
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+
+	sum := sha256.Sum256(content)
+	return fmt.Sprintf("%s-%s", a.Type, hex.EncodeToString(sum[:])[:8]), nil
+}
+
+func (a *ActionConfig) validateAndDefault(anchoredRegex bool) error {
+	if a.Type == "" {
+		// the configurations before type was introduced.
+		a.Type = a.Name
+	}
+	if a.Type == "" {
+		return errors.New("action type is required")
+	}
+	if a.Name != "" && a.Name != a.Type {
+		return errors.Newf("name %q conflicts with type %q, name is the deprecated alias of type", a.Name, a.Type)
+	}
+
+	for j := range a.Matchers {
+		a.Matchers[j].setAnchoredRegex(anchoredRegex)
+		if err := a.Matchers[j].ValidateAndDefault(); err != nil {
+			return errors.Wrapf(err, "matchers[%d]", j)
+		}
+	}
+	if a.Attrs == nil {
+		a.Attrs = map[string]string{}
+	}
+	if err := a.Retry.ValidateAndDefault(); err != nil {
+		return err
+	}
+	if a.Cooldown.Window < 0 {
+		return errors.New("cooldown window must not be negative")
+	}
+
+	if a.Type != SequenceActionName {
+		if len(a.Steps) != 0 {
+			return errors.Newf("steps are only available in the %s action", SequenceActionName)
+		}
+		return nil
+	}
+	if len(a.Steps) == 0 {
+		return errors.New("steps are required")
+	}
+	names := map[string]struct{}{}
+	for i := range a.Steps {
+		if err := a.Steps[i].validateAndDefault(); err != nil {
+			return errors.Wrapf(err, "steps[%d](%s)", i, a.Steps[i].Name)
+		}
+		if _, ok := names[a.Steps[i].Name]; ok {
+			return errors.Newf("steps[%d]: duplicate step name %q", i, a.Steps[i].Name)
+		}
+		names[a.Steps[i].Name] = struct{}{}
+	}
+
+	return nil
+}
+
+func (s *StepConfig) validateAndDefault() error {
+	if s.Name == "" {
+		return errors.New("step name is required")
+	}
+	if s.Action == "" {
+		return errors.New("step action is required")
+	}
+	if s.Action == SequenceActionName {
+		return errors.Newf("the %s action cannot be nested", SequenceActionName)
+	}
+	if s.Attrs == nil {
+		s.Attrs = map[string]string{}
+	}
+
+	if s.OnFailure == "" {
+		s.OnFailure = OnFailureAbort
+	}
+	switch s.OnFailure {
+	case OnFailureAbort, OnFailureContinue:
+		if s.Compensate != nil {
+			return errors.Newf("compensate requires onFailure %s", OnFailureCompensate)
+		}
+	case OnFailureCompensate:
+		if s.Compensate == nil {
+			return errors.New("compensate is required")
+		}
+		if s.Compensate.Name == "" {
+			s.Compensate.Name = s.Name + "-compensate"
+		}
+		if s.Compensate.Compensate != nil || (s.Compensate.OnFailure != "" && s.Compensate.OnFailure != OnFailureAbort) {
+			return errors.New("compensate cannot have its own onFailure policy")
+		}
+		if err := s.Compensate.validateAndDefault(); err != nil {
+			return errors.Wrap(err, "compensate")
+		}
+	default:
+		return errors.Newf("onFailure must be %s or %s or %s", OnFailureAbort, OnFailureContinue, OnFailureCompensate)
+	}
+
+	return nil
+}
+
+func (r *RouteConfig) validateAndDefault(anchoredRegex bool) error {
+	for i := range r.Matchers {
+		r.Matchers[i].setAnchoredRegex(anchoredRegex)
+		if err := r.Matchers[i].ValidateAndDefault(); err != nil {
+			return errors.Wrapf(err, "matchers[%d]", i)
+		}
+	}
+
+	for i := range r.Actions {
+		if err := r.Actions[i].validateAndDefault(anchoredRegex); err != nil {
+			return errors.Wrapf(err, "actions[%d](%s)", i, r.Actions[i].Type)
+		}
+	}
+
+	for i := range r.Routes {
+		if err := r.Routes[i].validateAndDefault(anchoredRegex); err != nil {
+			return errors.Wrapf(err, "routes[%d]", i)
+		}
+	}
+
+	return nil
+}
+
+func (q *QueueConfig) ValidateAndDefault() error {
+	if q.Size < 0 {
+		return errors.New("queue size must not be negative")
+	}
+	if q.Workers < 0 {
+		return errors.New("queue workers must not be negative")
+	}
+	if q.ActionTimeout < 0 {
+		return errors.New("queue actionTimeout must not be negative")
+	}
+
+	if q.Size == 0 {
+		q.Size = 100
+	}
+	if q.Workers == 0 {
+		q.Workers = 4
+	}
+	if q.ActionTimeout == 0 {
+		q.ActionTimeout = time.Minute
+	}
+
+	return nil
+}
+
+func (a *AuthConfig) ValidateAndDefault() error {
+	if a.BearerToken != nil {
+		if err := a.BearerToken.ValidateAndDefault(); err != nil {
+			return errors.Wrap(err, "bearerToken")
+		}
+	}
+
+	if a.BasicAuth != nil {
+		if a.BasicAuth.Username == "" {
+			return errors.New("basicAuth username is required")
+		}
+		if err := a.BasicAuth.Password.ValidateAndDefault(); err != nil {
+			return errors.Wrap(err, "basicAuth.password")
+		}
+	}
+
+	if a.HMAC != nil {
+		if err := a.HMAC.Secret.ValidateAndDefault(); err != nil {
+			return errors.Wrap(err, "hmac.secret")
+		}
+		if a.HMAC.Header == "" {
+			a.HMAC.Header = "X-Amgate-Signature"
+		}
+		if a.HMAC.Algorithm == "" {
+			a.HMAC.Algorithm = "sha256"
+		}
+		if a.HMAC.Algorithm != "sha256" && a.HMAC.Algorithm != "sha512" {
+			return errors.New("hmac algorithm must be sha256 or sha512")
+		}
+	}
+
+	return nil
+}
+
+func (r *RetryConfig) ValidateAndDefault() error {
+	if r.MaxAttempts < 0 {
+		return errors.New("retry maxAttempts must not be negative")
+	}
+	if r.InitialBackoff < 0 || r.MaxBackoff < 0 {
+		return errors.New("retry backoff must not be negative")
+	}
+	if r.Jitter < 0 || r.Jitter > 1 {
+		return errors.New("retry jitter must be between 0 and 1")
+	}
+
+	if r.MaxAttempts == 0 {
+		r.MaxAttempts = 1
+	}
+	if r.InitialBackoff == 0 {
+		r.InitialBackoff = time.Second
+	}
+	if r.MaxBackoff == 0 {
+		r.MaxBackoff = 30 * time.Second
+	}
+	if r.MaxBackoff < r.InitialBackoff {
+		return errors.New("retry maxBackoff must not be less than initialBackoff")
+	}
+	if r.RetryableReasons == nil {
+		r.RetryableReasons = slices.Clone(DefaultRetryableReasons)
 	}
 
 	return nil
@@ -120,12 +672,19 @@ func (m *MatcherConfig) ValidateAndDefault() error {
 	if m.Op == "" {
 		return errors.New("matcher op is required")
 	}
-	if m.Op != "=" && m.Op != "!=" && m.Op != "=~" {
-		return errors.New("matcher op must be = or != or =~")
+	if m.Op != "=" && m.Op != "!=" && m.Op != "=~" && m.Op != "!~" {
+		return errors.New("matcher op must be = or != or =~ or !~")
 	}
 	if m.Value == "" {
 		return errors.New("matcher value is required")
 	}
+	if m.Op == "=~" || m.Op == "!~" {
+		r, err := regexp.Compile(m.RegexPattern())
+		if err != nil {
+			return errors.Wrapf(err, "invalid regex %q", m.Value)
+		}
+		m.regexp = r
+	}
 
 	if m.Labels.Matchers == nil {
 		m.Labels.Matchers = []MatcherConfig{}
@@ -140,26 +699,67 @@ func (m *MatcherConfig) ValidateAndDefault() error {
 		m.CommonAnnotations.Matchers = []MatcherConfig{}
 	}
 
-	for _, subM := range m.Labels.Matchers {
-		if err := subM.ValidateAndDefault(); err != nil {
-			return err
+	for i := range m.Labels.Matchers {
+		if err := m.Labels.Matchers[i].ValidateAndDefault(); err != nil {
+			return errors.Wrapf(err, "labels.matchers[%d]", i)
 		}
 	}
-	for _, subM := range m.Annotations.Matchers {
-		if err := subM.ValidateAndDefault(); err != nil {
-			return err
+	for i := range m.Annotations.Matchers {
+		if err := m.Annotations.Matchers[i].ValidateAndDefault(); err != nil {
+			return errors.Wrapf(err, "annotations.matchers[%d]", i)
 		}
 	}
-	for _, subM := range m.CommonLabels.Matchers {
-		if err := subM.ValidateAndDefault(); err != nil {
-			return err
+	for i := range m.CommonLabels.Matchers {
+		if err := m.CommonLabels.Matchers[i].ValidateAndDefault(); err != nil {
+			return errors.Wrapf(err, "commonLabels.matchers[%d]", i)
 		}
 	}
-	for _, subM := range m.CommonAnnotations.Matchers {
-		if err := subM.ValidateAndDefault(); err != nil {
-			return err
+	for i := range m.CommonAnnotations.Matchers {
+		if err := m.CommonAnnotations.Matchers[i].ValidateAndDefault(); err != nil {
+			return errors.Wrapf(err, "commonAnnotations.matchers[%d]", i)
 		}
 	}
 
 	return nil
 }
+
+// RegexPattern returns the pattern that `=~` and `!~` evaluate.
+// the pattern is anchored to match the entire value for `!~`,
+// and for `=~` when MatchingConfig.AnchoredRegex is enabled.
+func (m *MatcherConfig) RegexPattern() string {
+	if m.Op == "!~" || m.anchoredRegex {
+		return "^(?:" + m.Value + ")$"
+	}
+	return m.Value
+}
+
+// Regexp returns the compiled RegexPattern.
+// the regex is compiled once by ValidateAndDefault, and compiled on demand otherwise.
+func (m *MatcherConfig) Regexp() (*regexp.Regexp, error) {
+	if m.regexp != nil {
+		return m.regexp, nil
+	}
+
+	r, err := regexp.Compile(m.RegexPattern())
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return r, nil
+}
+
+func (m *MatcherConfig) setAnchoredRegex(anchored bool) {
+	m.anchoredRegex = anchored
+
+	for i := range m.Labels.Matchers {
+		m.Labels.Matchers[i].setAnchoredRegex(anchored)
+	}
+	for i := range m.Annotations.Matchers {
+		m.Annotations.Matchers[i].setAnchoredRegex(anchored)
+	}
+	for i := range m.CommonLabels.Matchers {
+		m.CommonLabels.Matchers[i].setAnchoredRegex(anchored)
+	}
+	for i := range m.CommonAnnotations.Matchers {
+		m.CommonAnnotations.Matchers[i].setAnchoredRegex(anchored)
+	}
+}
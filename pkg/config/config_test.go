@@ -0,0 +1,330 @@
+package config_test
+
+import (
+	"testing"
+
+	"github.com/Drumato/amgate/pkg/config"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestConfig_ValidateAndDefault_Regex(t *testing.T) {
+	tests := []struct {
+		name    string
+		matcher config.MatcherConfig
+		wantErr string
+	}{
+		{
+			name:    "valid regex",
+			matcher: config.MatcherConfig{Key: "status", Op: "=~", Value: "fir.*"},
+		},
+		{
+			name:    "invalid regex",
+			matcher: config.MatcherConfig{Key: "status", Op: "=~", Value: "fir(.*"},
+			wantErr: `actions[0](k8s-rollout): matchers[0]: invalid regex "fir(.*"`,
+		},
+		{
+			name: "invalid nested regex",
+			matcher: config.MatcherConfig{
+				Key:   "status",
+				Op:    "=",
+				Value: "firing",
+				Labels: config.LabelMatcherConfig{
+					Matchers: []config.MatcherConfig{
+						{Key: "namespace", Op: "!~", Value: "kube-["},
+					},
+				},
+			},
+			wantErr: `actions[0](k8s-rollout): matchers[0]: labels.matchers[0]: invalid regex "kube-["`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.Config{
+				Actions: []config.ActionConfig{
+					{
+						Name:     "k8s-rollout",
+						Matchers: []config.MatcherConfig{tt.matcher},
+					},
+				},
+			}
+
+			err := cfg.ValidateAndDefault()
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+
+			r, err := cfg.Actions[0].Matchers[0].Regexp()
+			assert.NoError(t, err)
+			assert.Equal(t, "fir.*", r.String())
+		})
+	}
+}
+
+func TestConfig_ValidateAndDefault_Exec(t *testing.T) {
+	tests := []struct {
+		name            string
+		allowedCommands []string
+		want            []string
+		wantErr         string
+	}{
+		{
+			name:            "absolute paths",
+			allowedCommands: []string{"/scripts/restart-cache.sh", "/scripts/../bin/flush"},
+			want:            []string{"/scripts/restart-cache.sh", "/bin/flush"},
+		},
+		{
+			name:            "relative path",
+			allowedCommands: []string{"/scripts/restart-cache.sh", "flush"},
+			wantErr:         `exec: allowedCommands[1]: "flush" must be an absolute path`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.Config{
+				Exec: config.ExecConfig{AllowedCommands: tt.allowedCommands},
+			}
+
+			err := cfg.ValidateAndDefault()
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, cfg.Exec.AllowedCommands)
+		})
+	}
+}
+
+func TestConfig_ValidateAndDefault_Steps(t *testing.T) {
+	tests := []struct {
+		name    string
+		action  config.ActionConfig
+		want    []config.StepConfig
+		wantErr string
+	}{
+		{
+			name: "default onFailure",
+			action: config.ActionConfig{
+				Name: "sequence",
+				Steps: []config.StepConfig{
+					{Name: "scale", Action: "k8s-scale"},
+					{
+						Name: "restart", Action: "k8s-rollout", OnFailure: "compensate",
+						Compensate: &config.StepConfig{Action: "k8s-scale"},
+					},
+				},
+			},
+			want: []config.StepConfig{
+				{Name: "scale", Action: "k8s-scale", Attrs: map[string]string{}, OnFailure: "abort"},
+				{
+					Name: "restart", Action: "k8s-rollout", Attrs: map[string]string{}, OnFailure: "compensate",
+					Compensate: &config.StepConfig{Name: "restart-compensate", Action: "k8s-scale", Attrs: map[string]string{}, OnFailure: "abort"},
+				},
+			},
+		},
+		{
+			name:    "no steps",
+			action:  config.ActionConfig{Name: "sequence"},
+			wantErr: "actions[0](sequence): steps are required",
+		},
+		{
+			name: "steps of another action",
+			action: config.ActionConfig{
+				Name:  "k8s-rollout",
+				Steps: []config.StepConfig{{Name: "scale", Action: "k8s-scale"}},
+			},
+			wantErr: "steps are only available in the sequence action",
+		},
+		{
+			name: "duplicate name",
+			action: config.ActionConfig{
+				Name: "sequence",
+				Steps: []config.StepConfig{
+					{Name: "scale", Action: "k8s-scale"},
+					{Name: "scale", Action: "k8s-scale"},
+				},
+			},
+			wantErr: `steps[1]: duplicate step name "scale"`,
+		},
+		{
+			name: "nested sequence",
+			action: config.ActionConfig{
+				Name:  "sequence",
+				Steps: []config.StepConfig{{Name: "inner", Action: "sequence"}},
+			},
+			wantErr: "the sequence action cannot be nested",
+		},
+		{
+			name: "compensate without step",
+			action: config.ActionConfig{
+				Name:  "sequence",
+				Steps: []config.StepConfig{{Name: "scale", Action: "k8s-scale", OnFailure: "compensate"}},
+			},
+			wantErr: "compensate is required",
+		},
+		{
+			name: "unknown onFailure",
+			action: config.ActionConfig{
+				Name:  "sequence",
+				Steps: []config.StepConfig{{Name: "scale", Action: "k8s-scale", OnFailure: "retry"}},
+			},
+			wantErr: "onFailure must be abort or continue or compensate",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.Config{Actions: []config.ActionConfig{tt.action}}
+
+			err := cfg.ValidateAndDefault()
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, cfg.Actions[0].Steps)
+		})
+	}
+}
+
+func TestConfig_ValidateAndDefault_ActionID(t *testing.T) {
+	tests := []struct {
+		name    string
+		actions []config.ActionConfig
+		routes  []config.RouteConfig
+		wantIDs []string
+		wantErr string
+	}{
+		{
+			name: "default ids",
+			actions: []config.ActionConfig{
+				{ID: "rollout-api", Type: "k8s-rollout"},
+				{Type: "k8s-scale"},
+				{ID: "rollout-web", Type: "k8s-rollout"},
+			},
+			routes: []config.RouteConfig{
+				{Actions: []config.ActionConfig{{Type: "k8s-pod-delete"}}},
+			},
+			wantIDs: []string{"rollout-api", "k8s-scale", "rollout-web", "k8s-pod-delete"},
+		},
+		{
+			name: "id required for the type used more than once",
+			actions: []config.ActionConfig{
+				{Type: "k8s-rollout"},
+				{ID: "rollout-web", Type: "k8s-rollout"},
+			},
+			wantErr: `action type "k8s-rollout" is used more than once`,
+		},
+		{
+			name: "legacy name",
+			actions: []config.ActionConfig{
+				{Name: "k8s-rollout"},
+			},
+			wantIDs: []string{"k8s-rollout"},
+		},
+		{
+			name: "legacy names with the same content",
+			actions: []config.ActionConfig{
+				{Name: "k8s-rollout"},
+				{Name: "k8s-rollout"},
+			},
+			wantErr: "duplicate action id",
+		},
+		{
+			name: "name conflicts with type",
+			actions: []config.ActionConfig{
+				{Name: "k8s-rollout", Type: "k8s-scale"},
+			},
+			wantErr: `name "k8s-rollout" conflicts with type "k8s-scale"`,
+		},
+		{
+			name: "no type",
+			actions: []config.ActionConfig{
+				{ID: "rollout-web"},
+			},
+			wantErr: "action type is required",
+		},
+		{
+			name: "duplicate id across routes",
+			actions: []config.ActionConfig{
+				{ID: "restart", Type: "k8s-rollout"},
+			},
+			routes: []config.RouteConfig{
+				{Routes: []config.RouteConfig{
+					{Actions: []config.ActionConfig{{ID: "restart", Type: "k8s-pod-delete"}}},
+				}},
+			},
+			wantErr: `duplicate action id "restart"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.Config{Actions: tt.actions, Routes: tt.routes}
+
+			err := cfg.ValidateAndDefault()
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+
+			ids := []string{}
+			for _, a := range cfg.Actions {
+				ids = append(ids, a.ID)
+			}
+			for _, r := range cfg.Routes {
+				for _, a := range r.Actions {
+					ids = append(ids, a.ID)
+				}
+			}
+			assert.Equal(t, tt.wantIDs, ids)
+		})
+	}
+}
+
+func TestConfig_ValidateAndDefault_LegacyActionID(t *testing.T) {
+	api := `
+- name: k8s-rollout
+  matchers:
+  - key: alertname
+    op: "="
+    value: APIDown
+  attrs:
+    name: api
+`
+	web := `
+- name: k8s-rollout
+  matchers:
+  - key: alertname
+    op: "="
+    value: WebDown
+  attrs:
+    name: web
+`
+
+	ids := func(actions string) map[string]string {
+		t.Helper()
+		cfg, err := config.FromConfigMap(&corev1.ConfigMap{Data: map[string]string{"actions": actions}})
+		assert.NoError(t, err)
+		assert.NoError(t, cfg.ValidateAndDefault())
+		assert.Len(t, cfg.Deprecations(), 2)
+
+		ids := map[string]string{}
+		for _, a := range cfg.Actions {
+			assert.Regexp(t, `^k8s-rollout-[0-9a-f]{8}$`, a.ID)
+			ids[a.Attrs["name"]] = a.ID
+		}
+		return ids
+	}
+
+	// the ids do not depend on the order of the actions.
+	got := ids(api + web)
+	assert.Equal(t, got, ids(web+api))
+	assert.NotEqual(t, got["api"], got["web"])
+}
@@ -0,0 +1,97 @@
+package config
+
+import (
+	"context"
+	"os"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// SecretSource represents a secret value
+// that is read from a mounted file or a key of a Kubernetes Secret.
+// a rotated secret is applied without reload.
+// the values of Kubernetes Secrets used by server.auth are cached by the server for a short while,
+// and the mounted files are read on every use.
+type SecretSource struct {
+	// File is the path of the mounted file containing the value.
+	File string `yaml:"file,omitempty"`
+	// SecretRef refers to a key of a Kubernetes Secret.
+	SecretRef *SecretKeyRef `yaml:"secretRef,omitempty"`
+}
+
+// SecretKeyRef refers to a key of a Kubernetes Secret.
+type SecretKeyRef struct {
+	// Namespace is the namespace of the Secret.
+	// the default is the namespace of the amgate ConfigMap.
+	Namespace string `yaml:"namespace,omitempty"`
+	// Name is the name of the Secret.
+	Name string `yaml:"name"`
+	// Key is the key of the value in the Secret.
+	Key string `yaml:"key"`
+}
+
+func (s *SecretSource) ValidateAndDefault() error {
+	if (s.File == "") == (s.SecretRef == nil) {
+		return errors.New("exactly one of file or secretRef is required")
+	}
+
+	if s.SecretRef != nil {
+		if s.SecretRef.Name == "" {
+			return errors.New("secretRef name is required")
+		}
+		if s.SecretRef.Key == "" {
+			return errors.New("secretRef key is required")
+		}
+		if s.SecretRef.Namespace == "" {
+			s.SecretRef.Namespace = ConfigMapKey().Namespace
+		}
+	}
+
+	return nil
+}
+
+// Resolve reads the secret value.
+// the trailing newline of the value is trimmed,
+// and an empty value is an error not to accept an empty credential.
+func (s *SecretSource) Resolve(ctx context.Context, k8sClient client.Client) (string, error) {
+	if s.File != "" {
+		b, err := os.ReadFile(s.File)
+		if err != nil {
+			return "", errors.WithStack(err)
+		}
+		v := strings.TrimRight(string(b), "\r\n")
+		if v == "" {
+			return "", errors.Newf("file %s is empty", s.File)
+		}
+		return v, nil
+	}
+
+	if s.SecretRef == nil {
+		return "", errors.New("secret source is empty")
+	}
+	if k8sClient == nil {
+		return "", errors.New("k8s client is required to read secretRef")
+	}
+
+	secret := corev1.Secret{}
+	if err := k8sClient.Get(ctx, types.NamespacedName{
+		Namespace: s.SecretRef.Namespace,
+		Name:      s.SecretRef.Name,
+	}, &secret); err != nil {
+		return "", errors.WithStack(err)
+	}
+
+	v, ok := secret.Data[s.SecretRef.Key]
+	if !ok {
+		return "", errors.Newf("key %s is not found in secret %s/%s", s.SecretRef.Key, s.SecretRef.Namespace, s.SecretRef.Name)
+	}
+	value := strings.TrimRight(string(v), "\r\n")
+	if value == "" {
+		return "", errors.Newf("key %s of secret %s/%s is empty", s.SecretRef.Key, s.SecretRef.Namespace, s.SecretRef.Name)
+	}
+	return value, nil
+}
@@ -0,0 +1,135 @@
+package config
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Watcher watches the amgate ConfigMap and notifies every valid revision of the configuration.
+// an invalid revision is rejected and logged, so the last good configuration keeps being used.
+type Watcher struct {
+	logger        *slog.Logger
+	k8sClient     client.WithWatch
+	key           types.NamespacedName
+	onUpdate      func(*Config)
+	retryInterval time.Duration
+
+	lastResourceVersion string
+}
+
+// NewWatcher creates a new watcher for the ConfigMap specified by ConfigMapKey.
+// onUpdate is called with the validated and defaulted configuration.
+func NewWatcher(
+	logger *slog.Logger,
+	k8sClient client.WithWatch,
+	onUpdate func(*Config),
+) *Watcher {
+	return &Watcher{
+		logger:        logger.With(slog.String("component", "config-watcher")),
+		k8sClient:     k8sClient,
+		key:           ConfigMapKey(),
+		onUpdate:      onUpdate,
+		retryInterval: 5 * time.Second,
+	}
+}
+
+// Run watches the ConfigMap until ctx is done.
+// the watch is re-established when the API server closes it.
+func (w *Watcher) Run(ctx context.Context) error {
+	for {
+		if err := w.watch(ctx); err != nil {
+			w.logger.ErrorContext(ctx, "failed to watch configmap", slog.String("error", err.Error()))
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(w.retryInterval):
+		}
+	}
+}
+
+func (w *Watcher) watch(ctx context.Context) error {
+	// load the current revision first, and then watch the changes after it.
+	cm := corev1.ConfigMap{}
+	if err := w.k8sClient.Get(ctx, w.key, &cm); err != nil && !apierrors.IsNotFound(err) {
+		return errors.WithStack(err)
+	}
+	if cm.ResourceVersion != "" {
+		w.apply(ctx, &cm)
+	}
+
+	wi, err := w.k8sClient.Watch(ctx, &corev1.ConfigMapList{},
+		client.InNamespace(w.key.Namespace),
+		client.MatchingFields{"metadata.name": w.key.Name},
+		&client.ListOptions{Raw: &metav1.ListOptions{ResourceVersion: cm.ResourceVersion}},
+	)
+	if apierrors.IsForbidden(err) {
+		return errors.Wrap(err, "list and watch permissions on configmaps are required to reload the configuration")
+	}
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer wi.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case ev, ok := <-wi.ResultChan():
+			if !ok {
+				// the watch was closed by the API server.
+				return nil
+			}
+
+			switch ev.Type {
+			case watch.Added, watch.Modified:
+				cm, ok := ev.Object.(*corev1.ConfigMap)
+				if !ok || cm.Name != w.key.Name {
+					continue
+				}
+				w.apply(ctx, cm)
+			case watch.Deleted:
+				w.logger.WarnContext(ctx, "configmap was deleted, keep using the last configuration")
+			case watch.Error:
+				return errors.WithStack(apierrors.FromObject(ev.Object))
+			}
+		}
+	}
+}
+
+func (w *Watcher) apply(ctx context.Context, cm *corev1.ConfigMap) {
+	if cm.ResourceVersion != "" && cm.ResourceVersion == w.lastResourceVersion {
+		return
+	}
+	w.lastResourceVersion = cm.ResourceVersion
+
+	logger := w.logger.With(slog.String("resourceVersion", cm.ResourceVersion))
+
+	cfg, err := FromConfigMap(cm)
+	if err != nil {
+		logger.ErrorContext(ctx, "rejected configmap revision: failed to parse", slog.String("error", err.Error()))
+		return
+	}
+
+	if err := cfg.ValidateAndDefault(); err != nil {
+		logger.ErrorContext(ctx, "rejected configmap revision: failed to validate", slog.String("error", err.Error()))
+		return
+	}
+
+	for _, d := range cfg.Deprecations() {
+		logger.WarnContext(ctx, "deprecated configuration", slog.String("detail", d))
+	}
+
+	w.onUpdate(&cfg)
+	logger.InfoContext(ctx, "config reloaded")
+}
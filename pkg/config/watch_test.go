@@ -0,0 +1,75 @@
+package config_test
+
+import (
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Drumato/amgate/pkg/config"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestWatcher_Run(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	c := fake.NewClientBuilder().Build()
+
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "amgate-config",
+			Namespace: "amgate-system",
+		},
+		Data: map[string]string{
+			"actions": "- name: first\n",
+		},
+	}
+	assert.NoError(t, c.Create(t.Context(), cm))
+
+	var mu sync.Mutex
+	var got []config.Config
+	latest := func() []config.Config {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]config.Config{}, got...)
+	}
+
+	w := config.NewWatcher(logger, c, func(cfg *config.Config) {
+		mu.Lock()
+		defer mu.Unlock()
+		got = append(got, *cfg)
+	})
+	go func() {
+		assert.NoError(t, w.Run(t.Context()))
+	}()
+
+	assert.Eventually(t, func() bool { return len(latest()) == 1 }, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, "first", latest()[0].Actions[0].Name)
+	assert.Equal(t, 8080, latest()[0].Server.Port)
+
+	// keep updating until the watch is established.
+	assert.Eventually(t, func() bool {
+		cm.Data["actions"] = "- name: second\n"
+		assert.NoError(t, c.Update(t.Context(), cm))
+		cfgs := latest()
+		return cfgs[len(cfgs)-1].Actions[0].Name == "second"
+	}, 5*time.Second, 50*time.Millisecond)
+
+	// an invalid revision is rejected.
+	cm.Data["actions"] = "- attrs: {}\n"
+	assert.NoError(t, c.Update(t.Context(), cm))
+
+	cm.Data["actions"] = "- name: third\n"
+	assert.NoError(t, c.Update(t.Context(), cm))
+
+	assert.Eventually(t, func() bool {
+		cfgs := latest()
+		return cfgs[len(cfgs)-1].Actions[0].Name == "third"
+	}, 5*time.Second, 10*time.Millisecond)
+	for _, cfg := range latest() {
+		assert.NotEmpty(t, cfg.Actions[0].Name)
+	}
+}
@@ -1,17 +1,26 @@
 package dispatcher
 
 import (
-	"regexp"
-
 	"github.com/Drumato/amgate/pkg/alertmanager"
 	"github.com/Drumato/amgate/pkg/config"
+	"github.com/samber/lo"
 )
 
 type DispatchResult struct {
-	// ActionName is the name of the action that was dispatched.
+	// ActionName is the name of the registered action that was dispatched,
+	// that is the type of the action configuration.
 	ActionName string
-	Alert      DispatchAlert
-	Attrs      map[string]string
+	// ActionID identifies the action configuration that was dispatched.
+	ActionID string
+	Alert    DispatchAlert
+	Attrs    map[string]string
+	// Retry is the retry policy of the action.
+	Retry config.RetryConfig
+	// Cooldown is the deduplication policy of the action.
+	Cooldown config.CooldownConfig
+	// Steps are the steps of the sequence action.
+	// their attrs are rendered when each step runs.
+	Steps []config.StepConfig
 }
 
 type DispatchAlert struct {
@@ -29,42 +38,23 @@ type DispatchAlert struct {
 }
 
 // DispatchEventToActions dispatches the alertmanager webhook payload to the actions.
+// the flat actions are evaluated independently,
+// and then the routing tree is evaluated with its continue/stop semantics.
 func DispatchEventToActions(
 	cfg *config.Config,
 	payload alertmanager.WebhookPayload,
 ) []DispatchResult {
 	results := []DispatchResult{}
 
+	root := rootRoute(cfg)
 	for _, alert := range payload.Alerts {
-		for _, action := range cfg.Actions {
-			for _, matcher := range action.Matchers {
-				if !checkLabelMatcherMatchesToAlert(alert.Labels, matcher.Labels) {
-					goto nextAction
-				}
-				if !checkLabelMatcherMatchesToAlert(alert.Annotations, matcher.Annotations) {
-					goto nextAction
-				}
-				if !checkLabelMatcherMatchesToAlert(payload.CommonLabels, matcher.CommonLabels) {
-					goto nextAction
-				}
-				if !checkLabelMatcherMatchesToAlert(payload.CommonAnnotations, matcher.CommonAnnotations) {
-					goto nextAction
-				}
-
-				actualValues := map[string]string{
-					"status":       alert.Status,
-					"startsAt":     alert.StartsAt,
-					"endsAt":       alert.EndsAt,
-					"generatorURL": alert.GeneratorURL,
-					"fingerprint":  alert.Fingerprint,
-				}
-				if !checkMatcherMatchesToAlert(actualValues, matcher) {
-					goto nextAction
-				}
-			}
+		actions, _ := matchRoute(root, alert, payload)
 
+		for _, action := range actions {
 			results = append(results, DispatchResult{
-				ActionName: action.Name,
+				// Type is empty if the config is not defaulted, and Name is the deprecated alias of Type.
+				ActionName: lo.If(action.Type != "", action.Type).Else(action.Name),
+				ActionID:   action.ID,
 				Alert: DispatchAlert{
 					Alert:             alert,
 					Version:           payload.Version,
@@ -75,17 +65,107 @@ func DispatchEventToActions(
 					GroupLabels:       payload.GroupLabels,
 					CommonLabels:      payload.CommonLabels,
 					CommonAnnotations: payload.CommonAnnotations,
+					ExternalURL:       payload.ExternalURL,
 				},
-				Attrs: action.Attrs,
+				Attrs:    action.Attrs,
+				Retry:    action.Retry,
+				Cooldown: action.Cooldown,
+				Steps:    action.Steps,
 			})
-
-		nextAction:
 		}
 	}
 
 	return results
 }
 
+// rootRoute builds the root of the routing tree.
+// each flat action is a degenerate child route that always continues,
+// so it is evaluated independently of the others.
+func rootRoute(cfg *config.Config) config.RouteConfig {
+	root := config.RouteConfig{}
+	for _, action := range cfg.Actions {
+		root.Routes = append(root.Routes, config.RouteConfig{
+			Actions:  []config.ActionConfig{action},
+			Continue: true,
+		})
+	}
+	root.Routes = append(root.Routes, cfg.Routes...)
+
+	return root
+}
+
+// matchRoute returns the actions dispatched by the route, and whether the alert matches the route.
+func matchRoute(
+	route config.RouteConfig,
+	alert alertmanager.Alert,
+	payload alertmanager.WebhookPayload,
+) ([]config.ActionConfig, bool) {
+	if !checkMatchersMatchToAlert(route.Matchers, alert, payload) {
+		return nil, false
+	}
+
+	actions := []config.ActionConfig{}
+	childMatched := false
+	for _, child := range route.Routes {
+		childActions, ok := matchRoute(child, alert, payload)
+		if !ok {
+			continue
+		}
+
+		childMatched = true
+		actions = append(actions, childActions...)
+		if !child.Continue {
+			break
+		}
+	}
+	if childMatched {
+		return actions, true
+	}
+
+	for _, action := range route.Actions {
+		if checkMatchersMatchToAlert(action.Matchers, alert, payload) {
+			actions = append(actions, action)
+		}
+	}
+
+	return actions, true
+}
+
+// checkMatchersMatchToAlert reports whether the alert matches all of the matchers.
+func checkMatchersMatchToAlert(
+	matchers []config.MatcherConfig,
+	alert alertmanager.Alert,
+	payload alertmanager.WebhookPayload,
+) bool {
+	for _, matcher := range matchers {
+		if !checkLabelMatcherMatchesToAlert(alert.Labels, matcher.Labels) {
+			return false
+		}
+		if !checkLabelMatcherMatchesToAlert(alert.Annotations, matcher.Annotations) {
+			return false
+		}
+		if !checkLabelMatcherMatchesToAlert(payload.CommonLabels, matcher.CommonLabels) {
+			return false
+		}
+		if !checkLabelMatcherMatchesToAlert(payload.CommonAnnotations, matcher.CommonAnnotations) {
+			return false
+		}
+
+		actualValues := map[string]string{
+			"status":       alert.Status,
+			"startsAt":     alert.StartsAt,
+			"endsAt":       alert.EndsAt,
+			"generatorURL": alert.GeneratorURL,
+			"fingerprint":  alert.Fingerprint,
+		}
+		if !checkMatcherMatchesToAlert(actualValues, matcher) {
+			return false
+		}
+	}
+
+	return true
+}
+
 func checkLabelMatcherMatchesToAlert(
 	actualLabels map[string]string,
 	labelmatcher config.LabelMatcherConfig,
@@ -124,12 +204,18 @@ func checkMatcherOperationBtwValues(
 	case "!=":
 		return actualValue != matcher.Value
 	case "=~":
-		r, err := regexp.Compile(matcher.Value)
+		// the invalid regex is rejected by config.MatcherConfig.ValidateAndDefault
+		r, err := matcher.Regexp()
+		if err != nil {
+			return false
+		}
+		return r.MatchString(actualValue)
+	case "!~":
+		r, err := matcher.Regexp()
 		if err != nil {
-			// TODO: log
 			return false
 		}
-		return r.Match([]byte(actualValue))
+		return !r.MatchString(actualValue)
 	default:
 		return false
 	}
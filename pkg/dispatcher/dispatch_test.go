@@ -101,6 +101,42 @@ func TestDispatchEventToActions(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "alert matches to action with id",
+			cfg: &config.Config{
+				Actions: []config.ActionConfig{
+					{
+						ID:   "test-1",
+						Type: "test",
+						Matchers: []config.MatcherConfig{
+							{
+								Key:   "status",
+								Op:    "=",
+								Value: "firing",
+							},
+						},
+					},
+				},
+			},
+			payload: alertmanager.WebhookPayload{
+				Alerts: []alertmanager.Alert{
+					{
+						Status: "firing",
+					},
+				},
+			},
+			want: []DispatchResult{
+				{
+					ActionName: "test",
+					ActionID:   "test-1",
+					Alert: DispatchAlert{
+						Alert: alertmanager.Alert{
+							Status: "firing",
+						},
+					},
+				},
+			},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -250,6 +286,33 @@ func Test_checkMatchOperationBtwValues(t *testing.T) {
 			actual: "firing",
 			want:   true,
 		},
+		{
+			name: "regex matcher is not anchored by default",
+			matcher: config.MatcherConfig{
+				Op:    "=~",
+				Value: "ir",
+			},
+			actual: "firing",
+			want:   true,
+		},
+		{
+			name: "negative regex matcher",
+			matcher: config.MatcherConfig{
+				Op:    "!~",
+				Value: "kube-.*",
+			},
+			actual: "kube-system",
+			want:   false,
+		},
+		{
+			name: "negative regex matcher is anchored",
+			matcher: config.MatcherConfig{
+				Op:    "!~",
+				Value: "kube-.*",
+			},
+			actual: "app-kube-system",
+			want:   true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -258,3 +321,167 @@ func Test_checkMatchOperationBtwValues(t *testing.T) {
 		})
 	}
 }
+
+func TestDispatchEventToActions_AnchoredRegex(t *testing.T) {
+	payload := alertmanager.WebhookPayload{
+		Alerts: []alertmanager.Alert{
+			{
+				Status: "firing",
+				Labels: map[string]string{"namespace": "app-kube-system"},
+			},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		anchored bool
+		want     int
+	}{
+		{
+			name:     "unanchored",
+			anchored: false,
+			want:     1,
+		},
+		{
+			name:     "anchored",
+			anchored: true,
+			want:     0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{
+				Matching: config.MatchingConfig{AnchoredRegex: tt.anchored},
+				Actions: []config.ActionConfig{
+					{
+						Name: "test",
+						Matchers: []config.MatcherConfig{
+							{
+								Key:   "status",
+								Op:    "=",
+								Value: "firing",
+								Labels: config.LabelMatcherConfig{
+									Matchers: []config.MatcherConfig{
+										{Key: "namespace", Op: "=~", Value: "kube-.*"},
+									},
+								},
+							},
+						},
+					},
+				},
+			}
+			assert.NoError(t, cfg.ValidateAndDefault())
+
+			got := DispatchEventToActions(cfg, payload)
+			assert.Len(t, got, tt.want)
+		})
+	}
+}
+
+func TestDispatchEventToActions_Routes(t *testing.T) {
+	labelMatcher := func(key, op, value string) config.MatcherConfig {
+		return config.MatcherConfig{
+			Key:   "status",
+			Op:    "=",
+			Value: "firing",
+			Labels: config.LabelMatcherConfig{
+				Matchers: []config.MatcherConfig{{Key: key, Op: op, Value: value}},
+			},
+		}
+	}
+	action := func(name string) []config.ActionConfig {
+		return []config.ActionConfig{{Name: name}}
+	}
+
+	tests := []struct {
+		name   string
+		routes []config.RouteConfig
+		labels map[string]string
+		want   []string
+	}{
+		{
+			name: "stop at the first matching route",
+			routes: []config.RouteConfig{
+				{Matchers: []config.MatcherConfig{labelMatcher("severity", "=", "critical")}, Actions: action("first")},
+				{Matchers: []config.MatcherConfig{labelMatcher("team", "=", "sre")}, Actions: action("second")},
+			},
+			labels: map[string]string{"severity": "critical", "team": "sre"},
+			want:   []string{"first"},
+		},
+		{
+			name: "continue to the next route",
+			routes: []config.RouteConfig{
+				{Matchers: []config.MatcherConfig{labelMatcher("severity", "=", "critical")}, Actions: action("first"), Continue: true},
+				{Matchers: []config.MatcherConfig{labelMatcher("team", "=", "sre")}, Actions: action("second")},
+			},
+			labels: map[string]string{"severity": "critical", "team": "sre"},
+			want:   []string{"first", "second"},
+		},
+		{
+			name: "child route inherits the parent matchers",
+			routes: []config.RouteConfig{
+				{
+					Matchers: []config.MatcherConfig{labelMatcher("team", "=", "sre")},
+					Actions:  action("fallback"),
+					Routes: []config.RouteConfig{
+						{Matchers: []config.MatcherConfig{labelMatcher("severity", "=", "critical")}, Actions: action("child")},
+					},
+				},
+			},
+			labels: map[string]string{"severity": "critical", "team": "web"},
+			want:   []string{},
+		},
+		{
+			name: "child route takes precedence over the parent",
+			routes: []config.RouteConfig{
+				{
+					Matchers: []config.MatcherConfig{labelMatcher("team", "=", "sre")},
+					Actions:  action("fallback"),
+					Routes: []config.RouteConfig{
+						{Matchers: []config.MatcherConfig{labelMatcher("severity", "=", "critical")}, Actions: action("child")},
+					},
+				},
+			},
+			labels: map[string]string{"severity": "critical", "team": "sre"},
+			want:   []string{"child"},
+		},
+		{
+			name: "parent route is the fallback",
+			routes: []config.RouteConfig{
+				{
+					Matchers: []config.MatcherConfig{labelMatcher("team", "=", "sre")},
+					Actions:  action("fallback"),
+					Routes: []config.RouteConfig{
+						{Matchers: []config.MatcherConfig{labelMatcher("severity", "=", "critical")}, Actions: action("child")},
+					},
+				},
+			},
+			labels: map[string]string{"severity": "warning", "team": "sre"},
+			want:   []string{"fallback"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{
+				Actions: []config.ActionConfig{
+					{
+						Name:     "flat",
+						Matchers: []config.MatcherConfig{labelMatcher("severity", "=", "info")},
+					},
+				},
+				Routes: tt.routes,
+			}
+			assert.NoError(t, cfg.ValidateAndDefault())
+
+			payload := alertmanager.WebhookPayload{
+				Alerts: []alertmanager.Alert{{Status: "firing", Labels: tt.labels}},
+			}
+			got := []string{}
+			for _, result := range DispatchEventToActions(cfg, payload) {
+				got = append(got, result.ActionName)
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
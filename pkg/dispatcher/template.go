@@ -0,0 +1,114 @@
+package dispatcher
+
+import (
+	"encoding/json"
+	"regexp"
+	"slices"
+	"strings"
+	"text/template"
+
+	"github.com/cockroachdb/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// templateFuncs are the helper functions available in the attrs templates.
+var templateFuncs = template.FuncMap{
+	// default returns def if the value is empty.
+	// use it with index for an optional label: {{ index .Alert.Labels "team" | default "sre" }}
+	"default": func(def string, value string) string {
+		if value == "" {
+			return def
+		}
+		return value
+	},
+	"lower":      strings.ToLower,
+	"upper":      strings.ToUpper,
+	"trimPrefix": func(prefix string, s string) string { return strings.TrimPrefix(s, prefix) },
+	"trimSuffix": func(suffix string, s string) string { return strings.TrimSuffix(s, suffix) },
+	"regexReplace": func(pattern string, repl string, s string) (string, error) {
+		r, err := regexp.Compile(pattern)
+		if err != nil {
+			return "", errors.WithStack(err)
+		}
+		return r.ReplaceAllString(s, repl), nil
+	},
+	// toJson encodes the value in JSON, e.g. a quoted and escaped string for a JSON body.
+	"toJson": func(v any) (string, error) {
+		b, err := json.Marshal(v)
+		if err != nil {
+			return "", errors.WithStack(err)
+		}
+		return string(b), nil
+	},
+}
+
+// RenderAttrs renders each attr value as a Go text/template against data,
+// that is usually a DispatchAlert(e.g. `{{ .Alert.Labels.namespace }}`).
+// referring to a missing label is an error.
+// the attrs in listAttrs are YAML lists, and each element is rendered separately,
+// so that a value of the alert cannot add elements to the list.
+func RenderAttrs(attrs map[string]string, data any, listAttrs ...string) (map[string]string, error) {
+	rendered := make(map[string]string, len(attrs))
+	for k, v := range attrs {
+		if !strings.Contains(v, "{{") {
+			rendered[k] = v
+			continue
+		}
+
+		if slices.Contains(listAttrs, k) {
+			list, err := renderList(k, v, data)
+			if err != nil {
+				return nil, err
+			}
+			rendered[k] = list
+			continue
+		}
+
+		s, err := render(k, v, data)
+		if err != nil {
+			return nil, err
+		}
+		rendered[k] = s
+	}
+
+	return rendered, nil
+}
+
+// renderList parses the attr as a YAML list, renders its elements
+// and returns the list in JSON, that is also a YAML list.
+func renderList(k, v string, data any) (string, error) {
+	elems := []string{}
+	if err := yaml.Unmarshal([]byte(v), &elems); err != nil {
+		return "", errors.Wrapf(err, "failed to parse attr %s as a list", k)
+	}
+
+	for i := range elems {
+		s, err := render(k, elems[i], data)
+		if err != nil {
+			return "", err
+		}
+		elems[i] = s
+	}
+
+	b, err := json.Marshal(elems)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	return string(b), nil
+}
+
+func render(k, v string, data any) (string, error) {
+	tmpl, err := template.New(k).
+		Option("missingkey=error").
+		Funcs(templateFuncs).
+		Parse(v)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to parse attr %s", k)
+	}
+
+	b := strings.Builder{}
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", errors.Wrapf(err, "failed to render attr %s", k)
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,116 @@
+package dispatcher
+
+import (
+	"testing"
+
+	"github.com/Drumato/amgate/pkg/alertmanager"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenderAttrs(t *testing.T) {
+	alert := DispatchAlert{
+		Alert: alertmanager.Alert{
+			Labels: map[string]string{
+				"deployment": "checkout-api",
+				"namespace":  "Apps",
+				"pod":        "checkout-api-7d9c6b5f4-x2x7q",
+				"quoted":     `a", "--force`,
+			},
+			Annotations: map[string]string{
+				"summary": "\"quoted\"\nsummary",
+			},
+		},
+		CommonLabels: map[string]string{
+			"cluster": "prod",
+		},
+	}
+
+	tests := []struct {
+		name      string
+		attrs     map[string]string
+		listAttrs []string
+		want      map[string]string
+		wantErr   bool
+	}{
+		{
+			name:  "static attrs",
+			attrs: map[string]string{"kind": "Deployment"},
+			want:  map[string]string{"kind": "Deployment"},
+		},
+		{
+			name: "labels",
+			attrs: map[string]string{
+				"name":    "{{ .Alert.Labels.deployment }}",
+				"cluster": "{{ .CommonLabels.cluster }}",
+			},
+			want: map[string]string{
+				"name":    "checkout-api",
+				"cluster": "prod",
+			},
+		},
+		{
+			name: "helpers",
+			attrs: map[string]string{
+				"namespace": "{{ .Alert.Labels.namespace | lower }}",
+				"name":      `{{ .Alert.Labels.pod | regexReplace "-[a-z0-9]+-[a-z0-9]+$" "" }}`,
+				"short":     `{{ .Alert.Labels.deployment | trimPrefix "checkout-" }}`,
+				"team":      `{{ index .Alert.Labels "team" | default "sre" }}`,
+			},
+			want: map[string]string{
+				"namespace": "apps",
+				"name":      "checkout-api",
+				"short":     "api",
+				"team":      "sre",
+			},
+		},
+		{
+			name: "json",
+			attrs: map[string]string{
+				"body": `{"name": {{ .Alert.Labels.deployment | toJson }}, "summary": {{ .Alert.Annotations.summary | toJson }}}`,
+			},
+			want: map[string]string{
+				"body": `{"name": "checkout-api", "summary": "\"quoted\"\nsummary"}`,
+			},
+		},
+		{
+			name: "list attrs",
+			attrs: map[string]string{
+				"args":   `["--name", "{{ .Alert.Labels.deployment }}", "{{ .Alert.Labels.quoted }}"]`,
+				"single": `"{{ .Alert.Labels.quoted }}"`,
+			},
+			listAttrs: []string{"args"},
+			want: map[string]string{
+				// the quoted label stays one element.
+				"args":   `["--name","checkout-api","a\", \"--force"]`,
+				"single": `"a", "--force"`,
+			},
+		},
+		{
+			name:      "invalid list attrs",
+			attrs:     map[string]string{"args": "{{ .Alert.Labels.deployment }}"},
+			listAttrs: []string{"args"},
+			wantErr:   true,
+		},
+		{
+			name:    "missing label",
+			attrs:   map[string]string{"name": "{{ .Alert.Labels.statefulset }}"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid template",
+			attrs:   map[string]string{"name": "{{ .Alert.Labels.deployment "},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := RenderAttrs(tt.attrs, alert, tt.listAttrs...)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("RenderAttrs() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
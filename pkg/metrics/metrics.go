@@ -0,0 +1,105 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const namespace = "amgate"
+
+// Outcomes of an action run.
+const (
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+)
+
+// Reasons of a rejected webhook.
+const (
+	ReasonBadRequest     = "bad_request"
+	ReasonActionNotFound = "action_not_found"
+	ReasonQueueFull      = "queue_full"
+	ReasonUnauthorized   = "unauthorized"
+)
+
+// Metrics holds the Prometheus metrics of amgate.
+// the action metrics are labeled by the action name and the action id,
+// so the custom actions registered by framework users are also observed
+// and the configurations of the same action are distinguished.
+type Metrics struct {
+	// WebhooksReceived counts the webhook requests.
+	WebhooksReceived prometheus.Counter
+	// WebhooksRejected counts the webhook requests rejected by reason.
+	WebhooksRejected *prometheus.CounterVec
+	// AlertsProcessed counts the alerts in the accepted webhook payloads.
+	AlertsProcessed prometheus.Counter
+	// DispatchMatches counts the dispatch results by action and id.
+	DispatchMatches *prometheus.CounterVec
+	// ActionsSuppressed counts the executions suppressed by the cooldown by action and id.
+	ActionsSuppressed *prometheus.CounterVec
+	// ActionRuns counts the action executions by action, id and outcome.
+	ActionRuns *prometheus.CounterVec
+	// ActionDuration observes the duration of the action executions including retries.
+	ActionDuration *prometheus.HistogramVec
+	// HandlerDuration observes the latency of the webhook handler.
+	HandlerDuration prometheus.Histogram
+}
+
+// New creates the metrics and registers them to reg.
+func New(reg prometheus.Registerer) *Metrics {
+	m := &Metrics{
+		WebhooksReceived: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "webhooks_received_total",
+			Help:      "The number of the received webhook requests.",
+		}),
+		WebhooksRejected: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "webhooks_rejected_total",
+			Help:      "The number of the rejected webhook requests.",
+		}, []string{"reason"}),
+		AlertsProcessed: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "alerts_processed_total",
+			Help:      "The number of the processed alerts.",
+		}),
+		DispatchMatches: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "dispatch_matches_total",
+			Help:      "The number of the alerts matched to the actions.",
+		}, []string{"action", "id"}),
+		ActionsSuppressed: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "actions_suppressed_total",
+			Help:      "The number of the action executions suppressed by the cooldown.",
+		}, []string{"action", "id"}),
+		ActionRuns: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "action_runs_total",
+			Help:      "The number of the action executions by outcome.",
+		}, []string{"action", "id", "outcome"}),
+		ActionDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "action_duration_seconds",
+			Help:      "The duration of the action executions including retries.",
+			Buckets:   []float64{0.01, 0.05, 0.1, 0.5, 1, 5, 10, 30, 60, 120, 300},
+		}, []string{"action", "id"}),
+		HandlerDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "webhook_handler_duration_seconds",
+			Help:      "The latency of the webhook handler.",
+			Buckets:   prometheus.DefBuckets,
+		}),
+	}
+
+	reg.MustRegister(
+		m.WebhooksReceived,
+		m.WebhooksRejected,
+		m.AlertsProcessed,
+		m.DispatchMatches,
+		m.ActionsSuppressed,
+		m.ActionRuns,
+		m.ActionDuration,
+		m.HandlerDuration,
+	)
+
+	return m
+}
@@ -0,0 +1,122 @@
+package server
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/hex"
+	"hash"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/Drumato/amgate/pkg/config"
+	"github.com/Drumato/amgate/pkg/metrics"
+	"github.com/cockroachdb/errors"
+	"github.com/labstack/echo/v4"
+)
+
+// maxWebhookBodySize is the upper bound of the body read to verify the HMAC signature.
+const maxWebhookBodySize = 16 << 20
+
+var errUnauthorized = errors.New("unauthorized")
+
+// authMiddleware authenticates the webhook requests by server.auth of the current configuration.
+func (s *Server[T]) authMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		auth := s.Config().Server.Auth
+		if err := s.authenticate(c, auth); err != nil {
+			if errors.Is(err, errUnauthorized) {
+				s.logger.WarnContext(c.Request().Context(), "unauthorized webhook request",
+					slog.String("remoteAddr", c.RealIP()),
+					slog.String("error", err.Error()),
+				)
+				s.metrics.WebhooksRejected.WithLabelValues(metrics.ReasonUnauthorized).Inc()
+				return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+			}
+
+			s.logger.ErrorContext(c.Request().Context(), "failed to authenticate webhook request", slog.String("error", err.Error()))
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "failed to authenticate"})
+		}
+
+		return next(c)
+	}
+}
+
+func (s *Server[T]) authenticate(c echo.Context, auth config.AuthConfig) error {
+	ctx := c.Request().Context()
+
+	if auth.BearerToken != nil {
+		token, err := s.secrets.resolve(ctx, auth.BearerToken, s.K8sClient)
+		if err != nil {
+			return errors.Wrap(err, "failed to resolve bearer token")
+		}
+
+		got, ok := strings.CutPrefix(c.Request().Header.Get(echo.HeaderAuthorization), "Bearer ")
+		if !ok || !secureEqual(got, token) {
+			return errors.Wrap(errUnauthorized, "invalid bearer token")
+		}
+	}
+
+	if auth.BasicAuth != nil {
+		password, err := s.secrets.resolve(ctx, &auth.BasicAuth.Password, s.K8sClient)
+		if err != nil {
+			return errors.Wrap(err, "failed to resolve basic auth password")
+		}
+
+		gotUser, gotPassword, ok := c.Request().BasicAuth()
+		// evaluate both to avoid leaking which one is wrong by timing.
+		userOK := secureEqual(gotUser, auth.BasicAuth.Username)
+		passwordOK := secureEqual(gotPassword, password)
+		if !ok || !userOK || !passwordOK {
+			return errors.Wrap(errUnauthorized, "invalid basic auth credentials")
+		}
+	}
+
+	if auth.HMAC != nil {
+		key, err := s.secrets.resolve(ctx, &auth.HMAC.Secret, s.K8sClient)
+		if err != nil {
+			return errors.Wrap(err, "failed to resolve hmac secret")
+		}
+
+		body, err := io.ReadAll(io.LimitReader(c.Request().Body, maxWebhookBodySize))
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		// the handler reads the body again.
+		c.Request().Body = io.NopCloser(bytes.NewReader(body))
+
+		if !verifyHMAC(auth.HMAC, key, body, c.Request().Header.Get(auth.HMAC.Header)) {
+			return errors.Wrap(errUnauthorized, "invalid hmac signature")
+		}
+	}
+
+	return nil
+}
+
+func verifyHMAC(cfg *config.HMACConfig, key string, body []byte, signature string) bool {
+	var newHash func() hash.Hash
+	switch cfg.Algorithm {
+	case "sha512":
+		newHash = sha512.New
+	default:
+		newHash = sha256.New
+	}
+
+	signature = strings.TrimPrefix(signature, cfg.Algorithm+"=")
+	got, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+
+	mac := hmac.New(newHash, []byte(key))
+	mac.Write(body)
+	return hmac.Equal(got, mac.Sum(nil))
+}
+
+func secureEqual(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
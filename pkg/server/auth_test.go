@@ -0,0 +1,184 @@
+package server
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Drumato/amgate/pkg/config"
+	"github.com/Drumato/amgate/pkg/metrics"
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func writeSecretFile(t *testing.T, value string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "secret")
+	assert.NoError(t, os.WriteFile(path, []byte(value+"\n"), 0o600))
+	return path
+}
+
+func sign(key, body string) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(body))
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestServer_authMiddleware(t *testing.T) {
+	k8sClient := fake.NewClientBuilder().WithObjects(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "amgate-auth", Namespace: "amgate-system"},
+		Data:       map[string][]byte{"password": []byte("p@ss"), "empty": []byte("\n")},
+	}).Build()
+
+	tests := []struct {
+		name     string
+		auth     config.AuthConfig
+		setupReq func(req *http.Request)
+		wantCode int
+	}{
+		{
+			name:     "no auth",
+			auth:     config.AuthConfig{},
+			wantCode: http.StatusOK,
+		},
+		{
+			name: "bearer token",
+			auth: config.AuthConfig{
+				BearerToken: &config.SecretSource{File: writeSecretFile(t, "token")},
+			},
+			setupReq: func(req *http.Request) {
+				req.Header.Set(echo.HeaderAuthorization, "Bearer token")
+			},
+			wantCode: http.StatusOK,
+		},
+		{
+			name: "wrong bearer token",
+			auth: config.AuthConfig{
+				BearerToken: &config.SecretSource{File: writeSecretFile(t, "token")},
+			},
+			setupReq: func(req *http.Request) {
+				req.Header.Set(echo.HeaderAuthorization, "Bearer wrong")
+			},
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			name: "basic auth from secret",
+			auth: config.AuthConfig{
+				BasicAuth: &config.BasicAuthConfig{
+					Username: "alertmanager",
+					Password: config.SecretSource{SecretRef: &config.SecretKeyRef{Name: "amgate-auth", Key: "password"}},
+				},
+			},
+			setupReq: func(req *http.Request) {
+				req.SetBasicAuth("alertmanager", "p@ss")
+			},
+			wantCode: http.StatusOK,
+		},
+		{
+			name: "missing basic auth",
+			auth: config.AuthConfig{
+				BasicAuth: &config.BasicAuthConfig{
+					Username: "alertmanager",
+					Password: config.SecretSource{SecretRef: &config.SecretKeyRef{Name: "amgate-auth", Key: "password"}},
+				},
+			},
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			name: "hmac",
+			auth: config.AuthConfig{
+				HMAC: &config.HMACConfig{Secret: config.SecretSource{File: writeSecretFile(t, "key")}},
+			},
+			setupReq: func(req *http.Request) {
+				req.Header.Set("X-Amgate-Signature", sign("key", firingPayload))
+			},
+			wantCode: http.StatusOK,
+		},
+		{
+			name: "wrong hmac",
+			auth: config.AuthConfig{
+				HMAC: &config.HMACConfig{Secret: config.SecretSource{File: writeSecretFile(t, "key")}},
+			},
+			setupReq: func(req *http.Request) {
+				req.Header.Set("X-Amgate-Signature", sign("wrong", firingPayload))
+			},
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			name: "empty bearer token",
+			auth: config.AuthConfig{
+				BearerToken: &config.SecretSource{File: writeSecretFile(t, "")},
+			},
+			setupReq: func(req *http.Request) {
+				req.Header.Set(echo.HeaderAuthorization, "Bearer ")
+			},
+			wantCode: http.StatusInternalServerError,
+		},
+		{
+			name: "empty basic auth password",
+			auth: config.AuthConfig{
+				BasicAuth: &config.BasicAuthConfig{
+					Username: "alertmanager",
+					Password: config.SecretSource{SecretRef: &config.SecretKeyRef{Name: "amgate-auth", Key: "empty"}},
+				},
+			},
+			setupReq: func(req *http.Request) {
+				req.SetBasicAuth("alertmanager", "")
+			},
+			wantCode: http.StatusInternalServerError,
+		},
+		{
+			name: "unreadable secret",
+			auth: config.AuthConfig{
+				BearerToken: &config.SecretSource{File: filepath.Join(t.TempDir(), "missing")},
+			},
+			wantCode: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{Server: config.ServerConfig{Auth: tt.auth}}
+			assert.NoError(t, cfg.ValidateAndDefault())
+
+			logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+			s := New(echo.New(), cfg, WithLogger[struct{}](logger), WithK8sClient[struct{}](k8sClient))
+
+			req := httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(firingPayload))
+			if tt.setupReq != nil {
+				tt.setupReq(req)
+			}
+			rec := httptest.NewRecorder()
+			c := s.e.NewContext(req, rec)
+
+			var gotBody string
+			h := s.authMiddleware(func(c echo.Context) error {
+				b, err := io.ReadAll(c.Request().Body)
+				assert.NoError(t, err)
+				gotBody = string(b)
+				return c.NoContent(http.StatusOK)
+			})
+			assert.NoError(t, h(c))
+
+			assert.Equal(t, tt.wantCode, rec.Code)
+			if tt.wantCode == http.StatusOK {
+				assert.Equal(t, firingPayload, gotBody)
+			}
+			if tt.wantCode == http.StatusUnauthorized {
+				assert.Equal(t, 1.0, testutil.ToFloat64(s.metrics.WebhooksRejected.WithLabelValues(metrics.ReasonUnauthorized)))
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package server
+
+import (
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Drumato/amgate/pkg/dispatcher"
+)
+
+// deduplicator suppresses the repeated executions of an action for the same alert
+// while the cooldown window of the action is active.
+type deduplicator struct {
+	mu        sync.Mutex
+	expiresAt map[string]time.Time
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+func newDeduplicator() *deduplicator {
+	return &deduplicator{
+		expiresAt: map[string]time.Time{},
+		now:       time.Now,
+	}
+}
+
+// acquire reports whether the result may be executed.
+// it starts the cooldown window of the result if so.
+func (d *deduplicator) acquire(result dispatcher.DispatchResult) bool {
+	if result.Cooldown.Window <= 0 {
+		return true
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := d.now()
+	d.sweep(now)
+
+	key := dedupKey(result)
+	if expiresAt, ok := d.expiresAt[key]; ok && now.Before(expiresAt) {
+		return false
+	}
+
+	d.expiresAt[key] = now.Add(result.Cooldown.Window)
+	return true
+}
+
+// release cancels the cooldown window of the result,
+// so the next notification of the alert executes the action again.
+// it is used when the execution failed.
+func (d *deduplicator) release(result dispatcher.DispatchResult) {
+	if result.Cooldown.Window <= 0 {
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.expiresAt, dedupKey(result))
+}
+
+// sweep removes the expired windows at most once per minute.
+func (d *deduplicator) sweep(now time.Time) {
+	if now.Sub(d.lastSweep) < time.Minute {
+		return
+	}
+	d.lastSweep = now
+
+	maps.DeleteFunc(d.expiresAt, func(_ string, expiresAt time.Time) bool {
+		return !now.Before(expiresAt)
+	})
+}
+
+func dedupKey(result dispatcher.DispatchResult) string {
+	alert := result.Alert.Alert
+	fingerprint := alert.Fingerprint
+	if fingerprint == "" {
+		// fingerprint is always set by Alertmanager, but fall back to the labels just in case.
+		fingerprint = joinSorted(alert.Labels)
+	}
+
+	parts := []string{alert.Status, fingerprint, result.ActionID}
+	if result.Cooldown.PerTarget {
+		parts = append(parts, joinSorted(result.Attrs))
+	}
+	return strings.Join(parts, "\x00")
+}
+
+func joinSorted(m map[string]string) string {
+	b := strings.Builder{}
+	for _, k := range slices.Sorted(maps.Keys(m)) {
+		b.WriteString(k)
+		b.WriteByte('=')
+		b.WriteString(m[k])
+		b.WriteByte(',')
+	}
+	return b.String()
+}
@@ -0,0 +1,68 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Drumato/amgate/pkg/alertmanager"
+	"github.com/Drumato/amgate/pkg/config"
+	"github.com/Drumato/amgate/pkg/dispatcher"
+	"github.com/stretchr/testify/assert"
+)
+
+func newDedupResult(fingerprint string, attrs map[string]string, cooldown config.CooldownConfig) dispatcher.DispatchResult {
+	return dispatcher.DispatchResult{
+		ActionName: "k8s-rollout",
+		ActionID:   "k8s-rollout",
+		Alert: dispatcher.DispatchAlert{
+			Alert: alertmanager.Alert{Status: "firing", Fingerprint: fingerprint},
+		},
+		Attrs:    attrs,
+		Cooldown: cooldown,
+	}
+}
+
+func TestDeduplicator(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	d := newDeduplicator()
+	d.now = func() time.Time { return now }
+
+	cooldown := config.CooldownConfig{Window: 10 * time.Minute}
+	first := newDedupResult("a", map[string]string{"name": "app1"}, cooldown)
+
+	assert.True(t, d.acquire(first))
+	assert.False(t, d.acquire(first), "repeated notification is suppressed")
+	assert.True(t, d.acquire(newDedupResult("b", nil, cooldown)), "another alert is not suppressed")
+	assert.False(t, d.acquire(newDedupResult("a", map[string]string{"name": "app2"}, cooldown)), "attrs are ignored by default")
+
+	another := newDedupResult("a", nil, cooldown)
+	another.ActionID = "rollout-web"
+	assert.True(t, d.acquire(another), "another action of the same type is not suppressed")
+
+	resolved := first
+	resolved.Alert.Alert.Status = "resolved"
+	assert.True(t, d.acquire(resolved), "resolved notification is a different execution")
+
+	now = now.Add(10 * time.Minute)
+	assert.True(t, d.acquire(first), "window expired")
+
+	d.release(first)
+	assert.True(t, d.acquire(first), "window released")
+}
+
+func TestDeduplicator_PerTarget(t *testing.T) {
+	d := newDeduplicator()
+	cooldown := config.CooldownConfig{Window: time.Minute, PerTarget: true}
+
+	assert.True(t, d.acquire(newDedupResult("a", map[string]string{"name": "app1"}, cooldown)))
+	assert.True(t, d.acquire(newDedupResult("a", map[string]string{"name": "app2"}, cooldown)))
+	assert.False(t, d.acquire(newDedupResult("a", map[string]string{"name": "app1"}, cooldown)))
+}
+
+func TestDeduplicator_Disabled(t *testing.T) {
+	d := newDeduplicator()
+	result := newDedupResult("a", nil, config.CooldownConfig{})
+
+	assert.True(t, d.acquire(result))
+	assert.True(t, d.acquire(result))
+}
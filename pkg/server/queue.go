@@ -0,0 +1,79 @@
+package server
+
+import (
+	"context"
+	"sync"
+
+	"github.com/Drumato/amgate/pkg/dispatcher"
+	"github.com/cockroachdb/errors"
+)
+
+var errQueueFull = errors.New("action queue is full")
+
+// workQueue is a bounded in-process queue of the dispatched actions.
+// the workers started by start run the queued actions concurrently.
+type workQueue struct {
+	jobs chan dispatcher.DispatchResult
+	wg   sync.WaitGroup
+	// mu serializes the producers, so the room checked by enqueue is kept until it sends.
+	mu sync.Mutex
+}
+
+func newWorkQueue(size int) *workQueue {
+	return &workQueue{
+		jobs: make(chan dispatcher.DispatchResult, size),
+	}
+}
+
+// enqueue adds the results to the queue as one unit without blocking.
+// it returns errQueueFull and adds nothing if the queue has no room for all of them,
+// so that the results of a webhook are not executed again when Alertmanager resends it.
+func (q *workQueue) enqueue(results ...dispatcher.DispatchResult) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	// the workers only take the results out, so the room never shrinks while holding mu.
+	if cap(q.jobs)-len(q.jobs) < len(results) {
+		return errQueueFull
+	}
+	for _, result := range results {
+		q.jobs <- result
+	}
+	return nil
+}
+
+// start starts the workers that call run for each queued result.
+func (q *workQueue) start(
+	ctx context.Context,
+	workers int,
+	run func(ctx context.Context, result dispatcher.DispatchResult),
+) {
+	for range workers {
+		q.wg.Add(1)
+		go func() {
+			defer q.wg.Done()
+			for result := range q.jobs {
+				run(ctx, result)
+			}
+		}()
+	}
+}
+
+// stop stops accepting new results and waits for the workers to drain the queue.
+// enqueue must not be called after stop.
+func (q *workQueue) stop(ctx context.Context) error {
+	close(q.jobs)
+
+	done := make(chan struct{})
+	go func() {
+		q.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return errors.WithStack(ctx.Err())
+	}
+}
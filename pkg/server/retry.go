@@ -0,0 +1,82 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+	"math/rand/v2"
+	"net"
+	"slices"
+	"time"
+
+	"github.com/Drumato/amgate/pkg/action"
+	"github.com/Drumato/amgate/pkg/config"
+	"github.com/Drumato/amgate/pkg/dispatcher"
+	"github.com/cockroachdb/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// runWithRetry runs the action and retries it with exponential backoff
+// while the error is retryable and the attempts remain.
+func runWithRetry(
+	ctx context.Context,
+	logger *slog.Logger,
+	actor action.Action,
+	result dispatcher.DispatchResult,
+) error {
+	policy := result.Retry
+	backoff := policy.InitialBackoff
+
+	for attempt := 1; ; attempt++ {
+		err := actor.Run(ctx, result)
+		if err == nil {
+			return nil
+		}
+		if attempt >= policy.MaxAttempts || !isRetryable(err, policy) {
+			return err
+		}
+
+		wait := withJitter(backoff, policy.Jitter)
+		if seconds, ok := apierrors.SuggestsClientDelay(err); ok {
+			wait = max(wait, time.Duration(seconds)*time.Second)
+		}
+
+		logger.WarnContext(ctx, "retrying action",
+			slog.Int("attempt", attempt),
+			slog.Duration("backoff", wait),
+			slog.String("error", err.Error()),
+		)
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return errors.CombineErrors(err, ctx.Err())
+		case <-timer.C:
+		}
+
+		backoff = min(backoff*2, policy.MaxBackoff)
+	}
+}
+
+// isRetryable reports whether the error is transient according to the policy.
+func isRetryable(err error, policy config.RetryConfig) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		// the action timeout is exhausted.
+		return false
+	}
+
+	reason := apierrors.ReasonForError(err)
+	if reason != "" && slices.Contains(policy.RetryableReasons, string(reason)) {
+		return true
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+func withJitter(d time.Duration, jitter float64) time.Duration {
+	if jitter <= 0 {
+		return d
+	}
+	return d + time.Duration(rand.Float64()*jitter*float64(d))
+}
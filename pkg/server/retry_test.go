@@ -0,0 +1,177 @@
+package server
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/Drumato/amgate/pkg/config"
+	"github.com/Drumato/amgate/pkg/dispatcher"
+	"github.com/cockroachdb/errors"
+	"github.com/stretchr/testify/assert"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+type flakyAction struct {
+	errs  []error
+	calls int
+}
+
+func (a *flakyAction) Name() string {
+	return "flaky"
+}
+
+func (a *flakyAction) Run(_ context.Context, _ dispatcher.DispatchResult) error {
+	a.calls++
+	if a.calls <= len(a.errs) {
+		return a.errs[a.calls-1]
+	}
+	return nil
+}
+
+var deploymentsResource = schema.GroupResource{Group: "apps", Resource: "deployments"}
+
+func TestRunWithRetry(t *testing.T) {
+	conflict := errors.WithStack(apierrors.NewConflict(deploymentsResource, "app", errors.New("modified")))
+	notFound := errors.WithStack(apierrors.NewNotFound(deploymentsResource, "app"))
+
+	tests := []struct {
+		name      string
+		errs      []error
+		attempts  int
+		wantCalls int
+		wantErr   bool
+	}{
+		{
+			name:      "success",
+			attempts:  3,
+			wantCalls: 1,
+		},
+		{
+			name:      "retryable error recovers",
+			errs:      []error{conflict, conflict},
+			attempts:  3,
+			wantCalls: 3,
+		},
+		{
+			name:      "attempts exhausted",
+			errs:      []error{conflict, conflict, conflict},
+			attempts:  3,
+			wantCalls: 3,
+			wantErr:   true,
+		},
+		{
+			name:      "non retryable error",
+			errs:      []error{notFound},
+			attempts:  3,
+			wantCalls: 1,
+			wantErr:   true,
+		},
+		{
+			name:      "retry disabled",
+			errs:      []error{conflict},
+			attempts:  1,
+			wantCalls: 1,
+			wantErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy := config.RetryConfig{
+				MaxAttempts:    tt.attempts,
+				InitialBackoff: time.Millisecond,
+				MaxBackoff:     time.Millisecond,
+			}
+			assert.NoError(t, policy.ValidateAndDefault())
+
+			logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+			a := &flakyAction{errs: tt.errs}
+			err := runWithRetry(t.Context(), logger, a, dispatcher.DispatchResult{Retry: policy})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("runWithRetry() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			assert.Equal(t, tt.wantCalls, a.calls)
+		})
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	policy := config.RetryConfig{}
+	assert.NoError(t, policy.ValidateAndDefault())
+
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{
+			name: "conflict",
+			err:  apierrors.NewConflict(deploymentsResource, "app", errors.New("modified")),
+			want: true,
+		},
+		{
+			name: "too many requests",
+			err:  apierrors.NewTooManyRequests("slow down", 1),
+			want: true,
+		},
+		{
+			name: "wrapped server timeout",
+			err:  errors.WithStack(apierrors.NewServerTimeout(deploymentsResource, "patch", 1)),
+			want: true,
+		},
+		{
+			name: "forbidden",
+			err:  apierrors.NewForbidden(deploymentsResource, "app", errors.New("denied")),
+			want: false,
+		},
+		{
+			name: "deadline exceeded",
+			err:  errors.WithStack(context.DeadlineExceeded),
+			want: false,
+		},
+		{
+			name: "plain error",
+			err:  errors.New("boom"),
+			want: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, isRetryable(tt.err, policy))
+		})
+	}
+}
+
+func TestServer_runAction_SequenceRetry(t *testing.T) {
+	conflict := errors.WithStack(apierrors.NewConflict(deploymentsResource, "app", errors.New("modified")))
+
+	s, a := newTestServer(t, &config.Config{})
+	flaky := &flakyAction{errs: []error{conflict, conflict}}
+	assert.NoError(t, s.AddAction(flaky))
+
+	policy := config.RetryConfig{
+		MaxAttempts:    3,
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     time.Millisecond,
+	}
+	assert.NoError(t, policy.ValidateAndDefault())
+
+	s.runAction(t.Context(), dispatcher.DispatchResult{
+		ActionName: config.SequenceActionName,
+		ActionID:   "sequence",
+		Steps: []config.StepConfig{
+			{Name: "record", Action: "record", Attrs: map[string]string{}},
+			{Name: "flaky", Action: "flaky", Attrs: map[string]string{}},
+		},
+		Retry: policy,
+	})
+
+	// only the failed step is retried.
+	assert.Equal(t, 1, a.count())
+	assert.Equal(t, 3, flaky.calls)
+}
@@ -0,0 +1,69 @@
+package server
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/Drumato/amgate/pkg/config"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// secretCacheTTL is the duration while a value read from a Kubernetes Secret is reused.
+const secretCacheTTL = 30 * time.Second
+
+// secretCacheMaxStale is the duration while an expired value is used if the Secret cannot be read.
+const secretCacheMaxStale = 5 * time.Minute
+
+// secretCache caches the values of the Kubernetes Secrets used by server.auth,
+// so that a webhook does not wait for the API server on every request.
+// the mounted files are cheap to read, so they are read on every use as before.
+type secretCache struct {
+	mu      sync.Mutex
+	entries map[config.SecretKeyRef]secretCacheEntry
+	now     func() time.Time
+}
+
+type secretCacheEntry struct {
+	value     string
+	expiresAt time.Time
+}
+
+func newSecretCache() *secretCache {
+	return &secretCache{
+		entries: map[config.SecretKeyRef]secretCacheEntry{},
+		now:     time.Now,
+	}
+}
+
+// resolve returns the value of the source.
+// if reading an expired Secret fails, the last value is used up to secretCacheMaxStale,
+// so that the webhooks keep being authenticated while the API server is unavailable.
+// a deleted Secret is not served from the cache.
+func (c *secretCache) resolve(ctx context.Context, source *config.SecretSource, k8sClient client.Client) (string, error) {
+	if source.SecretRef == nil {
+		return source.Resolve(ctx, k8sClient)
+	}
+
+	key := *source.SecretRef
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	c.mu.Unlock()
+	if ok && c.now().Before(entry.expiresAt) {
+		return entry.value, nil
+	}
+
+	value, err := source.Resolve(ctx, k8sClient)
+	if err != nil {
+		if ok && !apierrors.IsNotFound(err) && c.now().Before(entry.expiresAt.Add(secretCacheMaxStale)) {
+			return entry.value, nil
+		}
+		return "", err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[key] = secretCacheEntry{value: value, expiresAt: c.now().Add(secretCacheTTL)}
+	return value, nil
+}
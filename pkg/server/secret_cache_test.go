@@ -0,0 +1,68 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Drumato/amgate/pkg/config"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+)
+
+func TestSecretCache_resolve(t *testing.T) {
+	gets := 0
+	var getErr error
+	k8sClient := fake.NewClientBuilder().WithObjects(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "amgate-auth", Namespace: "amgate-system"},
+		Data:       map[string][]byte{"token": []byte("token")},
+	}).WithInterceptorFuncs(interceptor.Funcs{
+		Get: func(ctx context.Context, c client.WithWatch, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+			gets++
+			if getErr != nil {
+				return getErr
+			}
+			return c.Get(ctx, key, obj, opts...)
+		},
+	}).Build()
+
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	cache := newSecretCache()
+	cache.now = func() time.Time { return now }
+	source := &config.SecretSource{SecretRef: &config.SecretKeyRef{Namespace: "amgate-system", Name: "amgate-auth", Key: "token"}}
+
+	resolve := func() (string, error) {
+		return cache.resolve(t.Context(), source, k8sClient)
+	}
+
+	value, err := resolve()
+	assert.NoError(t, err)
+	assert.Equal(t, "token", value)
+	_, _ = resolve()
+	assert.Equal(t, 1, gets, "the value is cached")
+
+	now = now.Add(secretCacheTTL)
+	_, _ = resolve()
+	assert.Equal(t, 2, gets, "the expired value is read again")
+
+	now = now.Add(secretCacheTTL)
+	getErr = apierrors.NewServiceUnavailable("unavailable")
+	value, err = resolve()
+	assert.NoError(t, err)
+	assert.Equal(t, "token", value, "the last value is used while the API server is unavailable")
+
+	now = now.Add(secretCacheMaxStale)
+	_, err = resolve()
+	assert.Error(t, err, "the last value is too old")
+
+	getErr = apierrors.NewNotFound(schema.GroupResource{Resource: "secrets"}, "amgate-auth")
+	now = now.Add(-secretCacheMaxStale)
+	_, err = resolve()
+	assert.Error(t, err, "the deleted secret is not served from the cache")
+}
@@ -7,13 +7,19 @@ import (
 	"log/slog"
 	"net"
 	"net/http"
+	"sync/atomic"
 	"time"
 
 	"github.com/Drumato/amgate/pkg/action"
 	"github.com/Drumato/amgate/pkg/alertmanager"
 	"github.com/Drumato/amgate/pkg/config"
 	"github.com/Drumato/amgate/pkg/dispatcher"
+	"github.com/Drumato/amgate/pkg/metrics"
+	"github.com/cockroachdb/errors"
 	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/samber/lo"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
@@ -21,12 +27,17 @@ import (
 // Server is the main struct for the server
 type Server[T comparable] struct {
 	e              *echo.Echo
-	cfg            *config.Config
+	cfg            atomic.Pointer[config.Config]
 	logger         *slog.Logger
 	CustomDepends  *T
 	K8sClient      client.Client
 	webhookHandler func(c echo.Context) error
 	actions        map[string]action.Action
+	queue          *workQueue
+	dedup          *deduplicator
+	secrets        *secretCache
+	registry       *prometheus.Registry
+	metrics        *metrics.Metrics
 }
 
 // Start starts the server
@@ -36,25 +47,61 @@ func (s *Server[T]) Start(ctx context.Context) error {
 	if s.logger == nil {
 		s.logger = slog.Default()
 	}
-	port := lo.If(s.cfg.Server.Port != 0, s.cfg.Server.Port).Else(8080)
-	host := lo.If(s.cfg.Server.Host != "", s.cfg.Server.Host).Else("") // all interfaces
+	cfg := s.Config()
+	port := lo.If(cfg.Server.Port != 0, cfg.Server.Port).Else(8080)
+	host := lo.If(cfg.Server.Host != "", cfg.Server.Host).Else("") // all interfaces
 
 	if s.webhookHandler == nil {
 		s.webhookHandler = s.defaultWebhookHandler
 	}
-	s.e.GET("/healthz", func(c echo.Context) error {
-		return c.JSON(http.StatusOK, map[string]string{"status": "ok"})
-	})
+	s.registerProbeRoutes(s.e)
+
+	s.e.POST("/webhook", s.webhookHandler, s.webhookMetricsMiddleware, s.authMiddleware)
 
-	s.e.POST("/webhook", s.webhookHandler)
+	var tlsReloader *tlsReloader
+	if cfg.Server.TLS != nil {
+		r, err := newTLSReloader(s.logger, *cfg.Server.TLS)
+		if err != nil {
+			return errors.Wrap(err, "failed to load tls files")
+		}
+		tlsReloader = r
+	}
+
+	// the workers outlive ctx to drain the queued actions on shutdown.
+	workerCtx, cancelWorkers := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancelWorkers()
+	workers := lo.If(cfg.Server.Queue.Workers != 0, cfg.Server.Queue.Workers).Else(4)
+	s.queue.start(workerCtx, workers, s.runAction)
 
 	go func() {
 		addr := net.JoinHostPort(host, fmt.Sprintf("%d", port))
-		if err := s.e.Start(addr); err != nil && err != http.ErrServerClosed {
+		var err error
+		if tlsReloader != nil {
+			s.e.TLSServer.Addr = addr
+			s.e.TLSServer.TLSConfig = tlsReloader.serverConfig()
+			err = s.e.StartServer(s.e.TLSServer)
+		} else {
+			err = s.e.Start(addr)
+		}
+		if err != nil && err != http.ErrServerClosed {
 			s.logger.ErrorContext(ctx, "failed to start server", slog.String("error", err.Error()))
 		}
 	}()
 
+	var healthEcho *echo.Echo
+	if cfg.Server.HealthPort != 0 {
+		healthEcho = echo.New()
+		healthEcho.HideBanner = true
+		s.registerProbeRoutes(healthEcho)
+
+		go func() {
+			addr := net.JoinHostPort(host, fmt.Sprintf("%d", cfg.Server.HealthPort))
+			if err := healthEcho.Start(addr); err != nil && err != http.ErrServerClosed {
+				s.logger.ErrorContext(ctx, "failed to start health server", slog.String("error", err.Error()))
+			}
+		}()
+	}
+
 	// Wait for interrupt signal to gracefully shut down the server with a timeout of 10 seconds.
 	<-ctx.Done()
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
@@ -62,10 +109,26 @@ func (s *Server[T]) Start(ctx context.Context) error {
 	if err := s.e.Shutdown(ctx); err != nil {
 		s.e.Logger.Fatal(err)
 	}
+	if healthEcho != nil {
+		if err := healthEcho.Shutdown(ctx); err != nil {
+			s.logger.ErrorContext(ctx, "failed to shut down health server", slog.String("error", err.Error()))
+		}
+	}
+	if err := s.queue.stop(ctx); err != nil {
+		s.logger.ErrorContext(ctx, "failed to drain action queue", slog.String("error", err.Error()))
+	}
 
 	return nil
 }
 
+// registerProbeRoutes registers the routes for probes and monitoring.
+func (s *Server[T]) registerProbeRoutes(e *echo.Echo) {
+	e.GET("/healthz", func(c echo.Context) error {
+		return c.JSON(http.StatusOK, map[string]string{"status": "ok"})
+	})
+	e.GET("/metrics", echo.WrapHandler(promhttp.HandlerFor(s.registry, promhttp.HandlerOpts{})))
+}
+
 func (s *Server[T]) defaultWebhookHandler(c echo.Context) (err error) {
 	defer func() {
 		if closeErr := c.Request().Body.Close(); closeErr != nil {
@@ -78,28 +141,177 @@ func (s *Server[T]) defaultWebhookHandler(c echo.Context) (err error) {
 
 	payload := alertmanager.WebhookPayload{}
 	if err := json.NewDecoder(c.Request().Body).Decode(&payload); err != nil {
+		s.metrics.WebhooksRejected.WithLabelValues(metrics.ReasonBadRequest).Inc()
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
 	}
+	s.metrics.AlertsProcessed.Add(float64(len(payload.Alerts)))
 
 	s.logger.DebugContext(c.Request().Context(), "received webhook payload", slog.Any("payload", payload))
 
-	dispatchResults := dispatcher.DispatchEventToActions(s.cfg, payload)
+	dispatchResults := dispatcher.DispatchEventToActions(s.Config(), payload)
 
 	for _, result := range dispatchResults {
 		s.logger.DebugContext(c.Request().Context(), "dispatch result", slog.Any("result", result))
 
-		actor, ok := s.actions[result.ActionName]
-		if !ok {
-			s.logger.ErrorContext(c.Request().Context(), "action not found", slog.String("action", result.ActionName))
-			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "action not found"})
+		s.metrics.DispatchMatches.WithLabelValues(result.ActionName, result.ActionID).Inc()
+
+		for _, name := range actionNames(result) {
+			if _, ok := s.actions[name]; !ok {
+				s.logger.ErrorContext(c.Request().Context(), "action not found", slog.String("action", name))
+				s.metrics.WebhooksRejected.WithLabelValues(metrics.ReasonActionNotFound).Inc()
+				return c.JSON(http.StatusInternalServerError, map[string]string{"error": "action not found"})
+			}
 		}
+	}
 
-		if err := actor.Run(c.Request().Context(), result); err != nil {
-			return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	accepted := []dispatcher.DispatchResult{}
+	for _, result := range dispatchResults {
+		attrs, err := dispatcher.RenderAttrs(result.Attrs, result.Alert, action.ListAttrs(s.actions[result.ActionName])...)
+		if err != nil {
+			s.logger.ErrorContext(c.Request().Context(), "failed to render attrs",
+				slog.String("action", result.ActionName),
+				slog.String("actionID", result.ActionID),
+				slog.String("fingerprint", result.Alert.Alert.Fingerprint),
+				slog.String("error", err.Error()),
+			)
+			s.metrics.ActionRuns.WithLabelValues(result.ActionName, result.ActionID, metrics.OutcomeFailure).Inc()
+			continue
 		}
+		result.Attrs = attrs
+
+		if !s.dedup.acquire(result) {
+			s.logger.InfoContext(c.Request().Context(), "action suppressed by cooldown",
+				slog.String("action", result.ActionName),
+				slog.String("actionID", result.ActionID),
+				slog.String("fingerprint", result.Alert.Alert.Fingerprint),
+			)
+			s.metrics.ActionsSuppressed.WithLabelValues(result.ActionName, result.ActionID).Inc()
+			continue
+		}
+
+		accepted = append(accepted, result)
 	}
 
-	return nil
+	// nothing is accepted on 503, as Alertmanager resends the whole notification.
+	if err := s.queue.enqueue(accepted...); err != nil {
+		for _, result := range accepted {
+			s.dedup.release(result)
+		}
+		s.metrics.WebhooksRejected.WithLabelValues(metrics.ReasonQueueFull).Inc()
+		s.logger.ErrorContext(c.Request().Context(), "failed to enqueue actions", slog.Int("actions", len(accepted)), slog.String("error", err.Error()))
+		return c.JSON(http.StatusServiceUnavailable, map[string]string{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusAccepted, map[string]string{"status": "accepted"})
+}
+
+// actionNames returns the names of the actions that the dispatched action runs,
+// including the steps of the sequence action.
+func actionNames(result dispatcher.DispatchResult) []string {
+	names := []string{result.ActionName}
+	for _, step := range result.Steps {
+		names = append(names, step.Action)
+		if step.Compensate != nil {
+			names = append(names, step.Compensate.Action)
+		}
+	}
+	return names
+}
+
+// runAction runs the dispatched action with the configured timeout.
+// it is called by the queue workers.
+func (s *Server[T]) runAction(ctx context.Context, result dispatcher.DispatchResult) {
+	logger := s.logger.With(slog.String("action", result.ActionName), slog.String("actionID", result.ActionID))
+
+	actor, ok := s.actions[result.ActionName]
+	if !ok {
+		logger.ErrorContext(ctx, "action not found")
+		return
+	}
+
+	timeout := s.Config().Server.Queue.ActionTimeout
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+	var err error
+	if result.ActionName == config.SequenceActionName {
+		// the sequence action retries each step by runStep,
+		// so that a retry does not run the succeeded steps again.
+		err = actor.Run(ctx, result)
+	} else {
+		err = runWithRetry(ctx, logger, actor, result)
+	}
+	s.metrics.ActionDuration.WithLabelValues(result.ActionName, result.ActionID).Observe(time.Since(start).Seconds())
+	if err != nil {
+		// let the next notification of the alert run the action again.
+		s.dedup.release(result)
+		s.metrics.ActionRuns.WithLabelValues(result.ActionName, result.ActionID, metrics.OutcomeFailure).Inc()
+		logger.ErrorContext(ctx, "failed to run action", slog.String("error", err.Error()))
+		return
+	}
+	s.metrics.ActionRuns.WithLabelValues(result.ActionName, result.ActionID, metrics.OutcomeSuccess).Inc()
+
+	logger.InfoContext(ctx, "action succeeded")
+}
+
+// webhookMetricsMiddleware observes the webhook requests
+// regardless of the handler set by WithWebhookHandler.
+func (s *Server[T]) webhookMetricsMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		s.metrics.WebhooksReceived.Inc()
+		start := time.Now()
+		defer func() {
+			s.metrics.HandlerDuration.Observe(time.Since(start).Seconds())
+		}()
+		return next(c)
+	}
+}
+
+// MetricsRegisterer returns the registerer of the metrics exposed on /metrics.
+// framework users can register the metrics of their custom actions to it.
+func (s *Server[T]) MetricsRegisterer() prometheus.Registerer {
+	return s.registry
+}
+
+// Config returns the configuration currently used by the server.
+func (s *Server[T]) Config() *config.Config {
+	return s.cfg.Load()
+}
+
+// allowedCommands returns the commands that the exec action may run in the current configuration.
+func (s *Server[T]) allowedCommands() []string {
+	cfg := s.Config()
+	if cfg == nil {
+		return nil
+	}
+	return cfg.Exec.AllowedCommands
+}
+
+// runStep runs a step of the sequence action with the retry policy of the sequence.
+func (s *Server[T]) runStep(ctx context.Context, actor action.Action, result dispatcher.DispatchResult) error {
+	logger := s.logger.With(slog.String("action", result.ActionName), slog.String("actionID", result.ActionID))
+	return runWithRetry(ctx, logger, actor, result)
+}
+
+// lookupAction returns the registered action, that resolves the steps of the sequence action.
+func (s *Server[T]) lookupAction(name string) (action.Action, bool) {
+	a, ok := s.actions[name]
+	return a, ok
+}
+
+// UpdateConfig atomically replaces the configuration used by the server.
+// the given configuration must be validated and defaulted already.
+// server.auth and server.queue.actionTimeout are read on use,
+// and the other keys of the server section are only read at startup, so changes to them require a restart.
+func (s *Server[T]) UpdateConfig(cfg *config.Config) {
+	old := s.cfg.Swap(cfg)
+	if old != nil && (old.Server.Host != cfg.Server.Host || old.Server.Port != cfg.Server.Port) {
+		s.logger.Warn("server host/port changes are applied after restart")
+	}
 }
 
 func (s *Server[T]) AddAction(a action.Action) error {
@@ -114,17 +326,55 @@ func (s *Server[T]) AddAction(a action.Action) error {
 // New creates a new server
 func New[T comparable](e *echo.Echo, cfg *config.Config, options ...ServerOption[T]) *Server[T] {
 
-	s := Server[T]{e: e, cfg: cfg}
+	s := Server[T]{e: e}
+	s.cfg.Store(cfg)
+	s.queue = newWorkQueue(lo.If(cfg.Server.Queue.Size != 0, cfg.Server.Queue.Size).Else(100))
+	s.dedup = newDeduplicator()
+	s.secrets = newSecretCache()
 
 	for _, o := range options {
 		o(&s)
 	}
+	// the metrics are registered after the options, so that WithMetricsRegistry takes effect.
+	if s.registry == nil {
+		s.registry = prometheus.NewRegistry()
+		s.registry.MustRegister(
+			collectors.NewGoCollector(),
+			collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		)
+	}
+	s.metrics = metrics.New(s.registry)
+	if s.logger == nil {
+		s.logger = slog.Default()
+	}
 
 	// add built-in actions
 	k8sRolloutAction := action.NewK8sRolloutAction(s.logger, s.K8sClient)
+	k8sRollbackAction := action.NewK8sRollbackAction(s.logger, s.K8sClient)
+	k8sScaleAction := action.NewK8sScaleAction(s.logger, s.K8sClient)
+	k8sPodDeleteAction := action.NewK8sPodDeleteAction(s.logger, s.K8sClient)
+	k8sNodeDrainAction := action.NewK8sNodeDrainAction(s.logger, s.K8sClient)
+	k8sJobAction := action.NewK8sJobAction(s.logger, s.K8sClient)
+	k8sPatchAction := action.NewK8sPatchAction(s.logger, s.K8sClient)
+	gitCommitAction := action.NewGitCommitAction(s.logger, s.K8sClient)
+	httpRequestAction := action.NewHTTPRequestAction(s.logger, s.K8sClient)
+	execAction := action.NewExecAction(s.logger, s.allowedCommands)
+	alertmanagerSilenceAction := action.NewAlertmanagerSilenceAction(s.logger)
+	sequenceAction := action.NewSequenceAction(s.logger, s.lookupAction, s.runStep)
 
 	s.actions = map[string]action.Action{
-		k8sRolloutAction.Name(): k8sRolloutAction,
+		k8sRolloutAction.Name():          k8sRolloutAction,
+		k8sRollbackAction.Name():         k8sRollbackAction,
+		k8sScaleAction.Name():            k8sScaleAction,
+		k8sPodDeleteAction.Name():        k8sPodDeleteAction,
+		k8sNodeDrainAction.Name():        k8sNodeDrainAction,
+		k8sJobAction.Name():              k8sJobAction,
+		k8sPatchAction.Name():            k8sPatchAction,
+		gitCommitAction.Name():           gitCommitAction,
+		httpRequestAction.Name():         httpRequestAction,
+		execAction.Name():                execAction,
+		alertmanagerSilenceAction.Name(): alertmanagerSilenceAction,
+		sequenceAction.Name():            sequenceAction,
 	}
 
 	return &s
@@ -153,6 +403,13 @@ func WithLogger[T comparable](logger *slog.Logger) ServerOption[T] {
 	}
 }
 
+// WithMetricsRegistry sets the registry of the metrics exposed on /metrics.
+func WithMetricsRegistry[T comparable](registry *prometheus.Registry) ServerOption[T] {
+	return func(s *Server[T]) {
+		s.registry = registry
+	}
+}
+
 func WithWebhookHandler[T comparable](handler func(c echo.Context) error) ServerOption[T] {
 	return func(s *Server[T]) {
 		s.webhookHandler = handler
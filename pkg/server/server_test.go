@@ -0,0 +1,177 @@
+package server
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Drumato/amgate/pkg/config"
+	"github.com/Drumato/amgate/pkg/dispatcher"
+	"github.com/Drumato/amgate/pkg/metrics"
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+type recordAction struct {
+	mu      sync.Mutex
+	results []dispatcher.DispatchResult
+	err     error
+}
+
+func (a *recordAction) Name() string {
+	return "record"
+}
+
+func (a *recordAction) Run(_ context.Context, result dispatcher.DispatchResult) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.results = append(a.results, result)
+	return a.err
+}
+
+func (a *recordAction) count() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return len(a.results)
+}
+
+func newTestServer(t *testing.T, cfg *config.Config) (*Server[struct{}], *recordAction) {
+	t.Helper()
+	assert.NoError(t, cfg.ValidateAndDefault())
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	s := New(echo.New(), cfg, WithLogger[struct{}](logger))
+	a := &recordAction{}
+	assert.NoError(t, s.AddAction(a))
+	return s, a
+}
+
+func postWebhook(s *Server[struct{}], body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := s.e.NewContext(req, rec)
+	_ = s.defaultWebhookHandler(c)
+	return rec
+}
+
+const firingPayload = `{"alerts":[{"status":"firing","labels":{"alertname":"test"}}]}`
+
+func recordActionConfig() config.ActionConfig {
+	return config.ActionConfig{
+		Name: "record",
+		Matchers: []config.MatcherConfig{
+			{Key: "status", Op: "=", Value: "firing"},
+		},
+	}
+}
+
+func TestServer_defaultWebhookHandler(t *testing.T) {
+	s, a := newTestServer(t, &config.Config{
+		Actions: []config.ActionConfig{recordActionConfig()},
+	})
+	s.queue.start(t.Context(), 1, s.runAction)
+
+	rec := postWebhook(s, firingPayload)
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+	assert.Eventually(t, func() bool { return a.count() == 1 }, time.Second, 10*time.Millisecond)
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(s.metrics.AlertsProcessed))
+	assert.Equal(t, 1.0, testutil.ToFloat64(s.metrics.DispatchMatches.WithLabelValues("record", "record")))
+	assert.Eventually(t, func() bool {
+		return testutil.ToFloat64(s.metrics.ActionRuns.WithLabelValues("record", "record", metrics.OutcomeSuccess)) == 1
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestServer_defaultWebhookHandler_BadRequest(t *testing.T) {
+	s, _ := newTestServer(t, &config.Config{})
+
+	rec := postWebhook(s, "{")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, 1.0, testutil.ToFloat64(s.metrics.WebhooksRejected.WithLabelValues(metrics.ReasonBadRequest)))
+}
+
+func TestServer_defaultWebhookHandler_ActionNotFound(t *testing.T) {
+	s, a := newTestServer(t, &config.Config{
+		Actions: []config.ActionConfig{
+			recordActionConfig(),
+			{Name: "unknown", Matchers: recordActionConfig().Matchers},
+		},
+	})
+
+	rec := postWebhook(s, firingPayload)
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.Len(t, s.queue.jobs, 0)
+	assert.Equal(t, 0, a.count())
+}
+
+func TestServer_defaultWebhookHandler_QueueFull(t *testing.T) {
+	s, _ := newTestServer(t, &config.Config{
+		Server:  config.ServerConfig{Queue: config.QueueConfig{Size: 1}},
+		Actions: []config.ActionConfig{recordActionConfig()},
+	})
+
+	// no worker is started, so the second request overflows the queue.
+	rec := postWebhook(s, firingPayload)
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+	rec = postWebhook(s, firingPayload)
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+}
+
+func TestServer_defaultWebhookHandler_ActionID(t *testing.T) {
+	first := recordActionConfig()
+	first.ID = "first"
+	second := recordActionConfig()
+	second.ID = "second"
+	s, a := newTestServer(t, &config.Config{
+		Actions: []config.ActionConfig{first, second},
+	})
+	s.queue.start(t.Context(), 1, s.runAction)
+
+	rec := postWebhook(s, firingPayload)
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+	assert.Eventually(t, func() bool { return a.count() == 2 }, time.Second, 10*time.Millisecond)
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(s.metrics.DispatchMatches.WithLabelValues("record", "first")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(s.metrics.DispatchMatches.WithLabelValues("record", "second")))
+	assert.Eventually(t, func() bool {
+		return testutil.ToFloat64(s.metrics.ActionRuns.WithLabelValues("record", "second", metrics.OutcomeSuccess)) == 1
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestServer_defaultWebhookHandler_QueueFull_Partial(t *testing.T) {
+	s, _ := newTestServer(t, &config.Config{
+		Server:  config.ServerConfig{Queue: config.QueueConfig{Size: 2}},
+		Actions: []config.ActionConfig{recordActionConfig()},
+	})
+
+	// the first request leaves the room for one result,
+	// so the second one with two alerts is rejected as a whole.
+	rec := postWebhook(s, firingPayload)
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+	rec = postWebhook(s, `{"alerts":[{"status":"firing","labels":{"alertname":"a"}},{"status":"firing","labels":{"alertname":"b"}}]}`)
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Len(t, s.queue.jobs, 1)
+}
+
+func TestNew_WithMetricsRegistry(t *testing.T) {
+	cfg := &config.Config{}
+	assert.NoError(t, cfg.ValidateAndDefault())
+	registry := prometheus.NewRegistry()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	s := New(echo.New(), cfg, WithLogger[struct{}](logger), WithMetricsRegistry[struct{}](registry))
+	assert.Same(t, registry, s.MetricsRegisterer())
+
+	count, err := testutil.GatherAndCount(registry, "amgate_webhooks_received_total")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count)
+}
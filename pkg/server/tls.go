@@ -0,0 +1,125 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/Drumato/amgate/pkg/config"
+	"github.com/cockroachdb/errors"
+)
+
+// tlsReloader provides the TLS configuration of the server
+// and reloads it when the certificate, the key or the client CA file changes.
+type tlsReloader struct {
+	cfg    config.TLSConfig
+	logger *slog.Logger
+
+	mu       sync.Mutex
+	modTimes []time.Time
+	current  *tls.Config
+}
+
+func newTLSReloader(logger *slog.Logger, cfg config.TLSConfig) (*tlsReloader, error) {
+	r := &tlsReloader{
+		cfg:    cfg,
+		logger: logger.With(slog.String("component", "tls-reloader")),
+	}
+
+	modTimes, err := r.stat()
+	if err != nil {
+		return nil, err
+	}
+	if err := r.load(modTimes); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// serverConfig returns the TLS configuration that the server listens with.
+func (r *tlsReloader) serverConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		GetConfigForClient: r.getConfigForClient,
+	}
+}
+
+// getConfigForClient checks the files on every handshake,
+// and keeps using the last valid configuration if the reload fails.
+func (r *tlsReloader) getConfigForClient(_ *tls.ClientHelloInfo) (*tls.Config, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	modTimes, err := r.stat()
+	if err != nil {
+		r.logger.Error("failed to stat tls files", slog.String("error", err.Error()))
+		return r.current, nil
+	}
+
+	changed := false
+	for i := range modTimes {
+		changed = changed || !modTimes[i].Equal(r.modTimes[i])
+	}
+	if changed {
+		if err := r.load(modTimes); err != nil {
+			r.logger.Error("failed to reload tls files", slog.String("error", err.Error()))
+		} else {
+			r.logger.Info("tls files reloaded")
+		}
+	}
+
+	return r.current, nil
+}
+
+func (r *tlsReloader) files() []string {
+	files := []string{r.cfg.CertFile, r.cfg.KeyFile}
+	if r.cfg.ClientCAFile != "" {
+		files = append(files, r.cfg.ClientCAFile)
+	}
+	return files
+}
+
+func (r *tlsReloader) stat() ([]time.Time, error) {
+	modTimes := []time.Time{}
+	for _, f := range r.files() {
+		info, err := os.Stat(f)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		modTimes = append(modTimes, info.ModTime())
+	}
+	return modTimes, nil
+}
+
+func (r *tlsReloader) load(modTimes []time.Time) error {
+	cert, err := tls.LoadX509KeyPair(r.cfg.CertFile, r.cfg.KeyFile)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	tlsCfg := &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{cert},
+	}
+
+	if r.cfg.ClientCAFile != "" {
+		pem, err := os.ReadFile(r.cfg.ClientCAFile)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return errors.Newf("no certificate is found in %s", r.cfg.ClientCAFile)
+		}
+		tlsCfg.ClientCAs = pool
+		tlsCfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	r.current = tlsCfg
+	r.modTimes = modTimes
+	return nil
+}
@@ -0,0 +1,155 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"log/slog"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Drumato/amgate/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+type testCert struct {
+	cert    *x509.Certificate
+	key     *ecdsa.PrivateKey
+	certPEM []byte
+	keyPEM  []byte
+}
+
+// newTestCert issues a certificate signed by parent, or a self-signed CA if parent is nil.
+func newTestCert(t *testing.T, serial int64, parent *testCert) *testCert {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "amgate"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+
+	parentCert, parentKey := tmpl, key
+	if parent == nil {
+		tmpl.IsCA = true
+		tmpl.BasicConstraintsValid = true
+	} else {
+		parentCert, parentKey = parent.cert, parent.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parentCert, &key.PublicKey, parentKey)
+	assert.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	assert.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	assert.NoError(t, err)
+
+	return &testCert{
+		cert:    cert,
+		key:     key,
+		certPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		keyPEM:  pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+	}
+}
+
+func writeTestCert(t *testing.T, dir string, c *testCert, modTime time.Time) config.TLSConfig {
+	t.Helper()
+
+	cfg := config.TLSConfig{
+		CertFile: filepath.Join(dir, "tls.crt"),
+		KeyFile:  filepath.Join(dir, "tls.key"),
+	}
+	assert.NoError(t, os.WriteFile(cfg.CertFile, c.certPEM, 0o600))
+	assert.NoError(t, os.WriteFile(cfg.KeyFile, c.keyPEM, 0o600))
+	assert.NoError(t, os.Chtimes(cfg.CertFile, modTime, modTime))
+	assert.NoError(t, os.Chtimes(cfg.KeyFile, modTime, modTime))
+	return cfg
+}
+
+func TestTLSReloader_Reload(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	dir := t.TempDir()
+	ca := newTestCert(t, 1, nil)
+	now := time.Now()
+
+	cfg := writeTestCert(t, dir, newTestCert(t, 2, ca), now)
+	r, err := newTLSReloader(logger, cfg)
+	assert.NoError(t, err)
+
+	got, err := r.getConfigForClient(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), got.Certificates[0].Leaf.SerialNumber.Int64())
+
+	// rotated by cert-manager.
+	writeTestCert(t, dir, newTestCert(t, 3, ca), now.Add(time.Minute))
+	got, err = r.getConfigForClient(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), got.Certificates[0].Leaf.SerialNumber.Int64())
+
+	// the broken files keep the last valid certificate.
+	assert.NoError(t, os.WriteFile(cfg.CertFile, []byte("broken"), 0o600))
+	assert.NoError(t, os.Chtimes(cfg.CertFile, now.Add(2*time.Minute), now.Add(2*time.Minute)))
+	got, err = r.getConfigForClient(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), got.Certificates[0].Leaf.SerialNumber.Int64())
+}
+
+func TestTLSReloader_MutualTLS(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	dir := t.TempDir()
+	ca := newTestCert(t, 1, nil)
+
+	cfg := writeTestCert(t, dir, newTestCert(t, 2, ca), time.Now())
+	cfg.ClientCAFile = filepath.Join(dir, "ca.crt")
+	assert.NoError(t, os.WriteFile(cfg.ClientCAFile, ca.certPEM, 0o600))
+
+	r, err := newTLSReloader(logger, cfg)
+	assert.NoError(t, err)
+
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	ts.TLS = r.serverConfig()
+	ts.StartTLS()
+	defer ts.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	newClient := func(certs ...tls.Certificate) *http.Client {
+		return &http.Client{Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{RootCAs: roots, Certificates: certs, MinVersion: tls.VersionTLS12},
+		}}
+	}
+
+	// without a client certificate.
+	_, err = newClient().Get(ts.URL)
+	assert.Error(t, err)
+
+	// with a client certificate signed by the client CA.
+	client := newTestCert(t, 4, ca)
+	clientCert, err := tls.X509KeyPair(client.certPEM, client.keyPEM)
+	assert.NoError(t, err)
+	resp, err := newClient(clientCert).Get(ts.URL)
+	assert.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}